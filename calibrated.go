@@ -0,0 +1,19 @@
+package rainchasers
+
+import "sort"
+
+// CalibratedURLs are the distinct gauge URLs used to calibrate any section
+func CalibratedURLs() []string {
+	isSeen := make(map[string]bool)
+	var urls []string
+	for _, calibrations := range Calibrations {
+		for _, c := range calibrations {
+			if !isSeen[c.URL] {
+				isSeen[c.URL] = true
+				urls = append(urls, c.URL)
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/eahydrology"
+)
+
+// Responds to environment variables:
+//   FROM (defaults to 30 days before TO)
+//   TO (defaults to yesterday)
+//   STATIONS (no default, comma separated alias URLs e.g. rloi://5020, blank for all)
+//   PROJECT_ID (no default, blank for validation mode)
+//   PUBSUB_TOPIC (no default, blank for validation mode)
+//   QUEUE_URL (no default, e.g. redis://localhost:6379/gauge, blank uses Pub/Sub)
+func main() {
+	cfg := eahydrology.Backfill{
+		ProjectID:           os.Getenv("PROJECT_ID"),
+		TopicName:           os.Getenv("PUBSUB_TOPIC"),
+		QueueURL:            os.Getenv("QUEUE_URL"),
+		MaxPublishPerSecond: 5,
+	}
+	if stations := os.Getenv("STATIONS"); stations != "" {
+		cfg.AliasURLs = strings.Split(stations, ",")
+	}
+
+	var err error
+	cfg.To = time.Now().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	if to := os.Getenv("TO"); to != "" {
+		cfg.To, err = time.Parse("2006-01-02", to)
+		exitOnErr(err)
+	}
+	cfg.From = cfg.To.AddDate(0, 0, -30)
+	if from := os.Getenv("FROM"); from != "" {
+		cfg.From, err = time.Parse("2006-01-02", from)
+		exitOnErr(err)
+	}
+
+	d := daemon.New("eahydrology")
+	d.Run(context.Background(), func(ctx context.Context, d *daemon.Supervisor) error {
+		// backfill is complete once run
+		defer d.Close()
+		return cfg.Run(ctx, d)
+	})
+	d.CloseAfter(24 * time.Hour)
+	d.Wait()
+	exitOnErr(d.Err())
+}
+
+func exitOnErr(err error) {
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+}
@@ -1,21 +1,51 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 
 	"github.com/algolia/algoliasearch-client-go/algoliasearch"
 	"github.com/robtuley/rainchasers"
+	"github.com/robtuley/rainchasers/internal/content"
+	"github.com/robtuley/rainchasers/internal/daemon"
 	"github.com/robtuley/rainchasers/internal/river"
 )
 
+// Responds to environment variables:
+//   CONTENT_DIR (no default, e.g. ./rivers, blank uses compiled in content)
+//   WATCH (no default, set to lint again whenever CONTENT_DIR changes)
 func main() {
+	dir := os.Getenv("CONTENT_DIR")
+	if dir == "" {
+		lint(content.New(rainchasers.Sections, rainchasers.Calibrations))
+		return
+	}
+
+	cn, err := content.Load(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lint(cn)
+	if os.Getenv("WATCH") == "" {
+		return
+	}
+	content.NewWatcher(dir, func(ctx context.Context, cn *content.Content) {
+		lint(cn)
+	}).Watch(context.Background(), daemon.NewLogger("lint"))
+}
+
+func lint(cn *content.Content) {
 	r := &result{}
+	for _, w := range cn.Warnings {
+		fmt.Println("warning: " + w.Error())
+	}
 
 sectionLoop:
-	for _, s := range rainchasers.Sections {
-		cals, _ := rainchasers.Calibrations[s.UUID]
+	for _, s := range cn.Sections {
+		cals, _ := cn.Calibrations[s.UUID]
 		r.AddToStats(s, cals)
 
 		if len(cals) == 0 {
@@ -1,132 +1,80 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/robtuley/rainchasers"
 	"github.com/robtuley/rainchasers/internal/daemon"
-	"github.com/robtuley/rainchasers/internal/gauge"
-	"github.com/robtuley/rainchasers/internal/queue"
-	"github.com/robtuley/report"
+	"github.com/robtuley/rainchasers/internal/nrw"
 )
 
 // Responds to environment variables:
 //   PROJECT_ID (no default, blank for validation mode)
 //   PUBSUB_TOPIC (no default, blank for validation mode)
+//   QUEUE_URL (no default, e.g. redis://localhost:6379/gauge, blank uses Pub/Sub)
+//   STATE_PATH (no default, e.g. /var/lib/rainchasers/dedupe.json, blank keeps the last published reading times in memory)
 //   NRW_API_KEY (no default)
+//   BACKFILL_DAYS (no default, blank polls recent readings, N backfills the last N days then exits)
+//   STATIONS (no default, comma separated alias or data URLs to backfill e.g. rloi://4155, blank for all)
 func main() {
-	cfg := config{
-		ProjectID:                os.Getenv("PROJECT_ID"),
-		TopicName:                os.Getenv("PUBSUB_TOPIC"),
-		APIKey:                   os.Getenv("NRW_API_KEY"),
-		RefreshPeriodInSeconds:   15 * 60,
-		MaxPublishPerSecond:      30,
-		ExitAfterXConsecutiveErr: 3,
+	if days := os.Getenv("BACKFILL_DAYS"); days != "" {
+		backfill(days)
+		return
+	}
+
+	cfg := nrw.Poller{
+		ProjectID:                        os.Getenv("PROJECT_ID"),
+		TopicName:                        os.Getenv("PUBSUB_TOPIC"),
+		QueueURL:                         os.Getenv("QUEUE_URL"),
+		APIKey:                           os.Getenv("NRW_API_KEY"),
+		RefreshPeriodInSeconds:           5 * 60,
+		PriorityURLs:                     rainchasers.CalibratedURLs(),
+		BackgroundRefreshPeriodInSeconds: 15 * 60,
+		MaxPublishPerSecond:              30,
+		StatePath:                        os.Getenv("STATE_PATH"),
+		ExitAfterXConsecutiveErr:         3,
 	}
 
 	d := daemon.New("nrw")
-	d.Run(context.Background(), cfg.run)
+	d.Run(context.Background(), cfg.Run)
 	d.CloseAfter(24 * time.Hour)
 	d.Wait()
-	if err := d.Err(); err != nil {
-		os.Stderr.WriteString(err.Error() + "\n")
-		os.Exit(1)
-	}
+	exitOnErr(d.Err())
 }
 
-type config struct {
-	ProjectID                string
-	TopicName                string
-	APIKey                   string
-	RefreshPeriodInSeconds   int
-	MaxPublishPerSecond      int
-	ExitAfterXConsecutiveErr int
-}
-
-func (cfg config) run(ctx context.Context, d *daemon.Supervisor) error {
-	// open connection to pubsub
-	topic, span := queue.New(ctx, cfg.ProjectID, cfg.TopicName)
-	d.Trace(span)
-	if err := span.Err(); err != nil {
-		return err
+func backfill(days string) {
+	cfg := nrw.Backfill{
+		ProjectID:           os.Getenv("PROJECT_ID"),
+		TopicName:           os.Getenv("PUBSUB_TOPIC"),
+		QueueURL:            os.Getenv("QUEUE_URL"),
+		APIKey:              os.Getenv("NRW_API_KEY"),
+		MaxPublishPerSecond: 5,
 	}
-	defer topic.Stop()
-
-	nConsecutiveErr := 0
-pollLoop:
-	for {
-		err := func() error {
-			// get recent stations & readings
-			var snapshots []gauge.Snapshot
-			var err error
-			var span report.Span
-			if cfg.APIKey == "" {
-				snapshots, err = parseRecent(bytes.NewBufferString(jsonResponseFromAPI))
-			} else {
-				snapshots, span = recent(ctx, cfg.APIKey)
-				d.Trace(span)
-				err = span.Err()
-			}
-			if err != nil {
-				return err
-			}
-
-			// calculate update rate to refresh on schedule
-			every := cfg.durationBetweenPublish(len(snapshots))
-			ticker := time.NewTicker(every)
-			defer ticker.Stop()
-
-			// publish snapshots
-			for _, s := range snapshots {
-				span := topic.Publish(ctx, &s)
-				d.Trace(span)
-				if err := span.Err(); err != nil {
-					return err
-				}
-
-				select {
-				case <-ticker.C:
-				case <-ctx.Done():
-					return nil
-				}
-			}
-
-			return nil
-		}()
-
-		// exit loop if shutdown
-		select {
-		case <-ctx.Done():
-			break pollLoop
-		default:
-		}
-
-		// track consecutive errors
-		if err != nil {
-			nConsecutiveErr++
-			if nConsecutiveErr >= cfg.ExitAfterXConsecutiveErr {
-				// ignore a few isolated errors, but if
-				// many consecutive bubble up to restart
-				return err
-			}
-		} else {
-			nConsecutiveErr = 0
-		}
+	var err error
+	cfg.Days, err = strconv.Atoi(days)
+	exitOnErr(err)
+	if stations := os.Getenv("STATIONS"); stations != "" {
+		cfg.AliasURLs = strings.Split(stations, ",")
 	}
 
-	return nil
+	d := daemon.New("nrw")
+	d.Run(context.Background(), func(ctx context.Context, d *daemon.Supervisor) error {
+		// backfill is complete once run
+		defer d.Close()
+		return cfg.Run(ctx, d)
+	})
+	d.CloseAfter(24 * time.Hour)
+	d.Wait()
+	exitOnErr(d.Err())
 }
 
-func (cfg config) durationBetweenPublish(total int) time.Duration {
-	ms := cfg.RefreshPeriodInSeconds * 1000 / total
-	min := 1
-	if cfg.MaxPublishPerSecond > 0 {
-		min = 1000 / cfg.MaxPublishPerSecond
-	}
-	if ms < min {
-		ms = min
+func exitOnErr(err error) {
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
 	}
-	return time.Millisecond * time.Duration(ms)
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/robtuley/rainchasers"
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/ea"
+	"github.com/robtuley/rainchasers/internal/nrw"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/rainchasers/internal/sepa"
+	"github.com/robtuley/rainchasers/internal/store"
+	"github.com/robtuley/report"
+)
+
+// Runs a single poll of each gauge source through an in-memory store and
+// prints a river level report once every snapshot is processed.
+//
+// Responds to environment variables:
+//   FIXTURE_DIR (no default, blank polls the live APIs)
+//   FIXTURE_MODE (default replay, or record to save live API responses)
+//   NRW_API_KEY (no default, blank uses a recorded NRW response)
+//   PIPELINE_TIME (defaults to now, RFC3339 time the fixtures were recorded)
+func main() {
+	p := pipeline{
+		QueueName: "pipeline",
+		NRWAPIKey: os.Getenv("NRW_API_KEY"),
+		Time:      time.Now(),
+		Report:    &bytes.Buffer{},
+	}
+	if t := os.Getenv("PIPELINE_TIME"); t != "" {
+		var err error
+		p.Time, err = time.Parse(time.RFC3339, t)
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	}
+	if dir := os.Getenv("FIXTURE_DIR"); dir != "" {
+		if os.Getenv("FIXTURE_MODE") == "record" {
+			daemon.Record(dir)
+		} else {
+			daemon.Replay(dir)
+		}
+	}
+
+	d := daemon.New("pipeline")
+	d.Run(context.Background(), p.run)
+	d.CloseAfter(time.Hour)
+	d.Wait()
+	if err := d.Err(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+	p.Report.WriteTo(os.Stdout)
+}
+
+type pipeline struct {
+	QueueName string
+	NRWAPIKey string
+	Time      time.Time
+	Report    *bytes.Buffer
+}
+
+func (p pipeline) run(ctx context.Context, d *daemon.Supervisor) error {
+	queueURL := "mem://" + p.QueueName
+	consumerGroup := "store"
+
+	// a durable consumer group means no snapshot is lost
+	// before the store has subscribed
+	broker := queue.SharedMemory(p.QueueName)
+	broker.CreateGroup(consumerGroup)
+
+	// init the store in memory with no algolia
+	c := store.New(d.Logger)
+	c.QueueURL = queueURL
+	c.ConsumerGroup = consumerGroup
+	c.Now = func() time.Time { return p.Time }
+	if err := c.Init(ctx, d); err != nil {
+		return err
+	}
+	d.Run(ctx, c.SubscribeToSnapshots)
+
+	// run a single cycle of each poller, calibrated gauges first
+	priorityURLs := rainchasers.CalibratedURLs()
+	pollers := []func(ctx context.Context, d *daemon.Supervisor) error{
+		ea.Poller{
+			QueueURL:                 queueURL,
+			BatchSize:                50,
+			PriorityURLs:             priorityURLs,
+			RefreshPeriodInSeconds:   1,
+			ExitAfterXConsecutiveErr: 1,
+			Cycles:                   1,
+		}.Run,
+		sepa.Poller{
+			QueueURL:                 queueURL,
+			PriorityURLs:             priorityURLs,
+			RefreshPeriodInSeconds:   1,
+			ExitAfterXConsecutiveErr: 1,
+			Cycles:                   1,
+		}.Run,
+		nrw.Poller{
+			QueueURL:                 queueURL,
+			PriorityURLs:             priorityURLs,
+			APIKey:                   p.NRWAPIKey,
+			RefreshPeriodInSeconds:   1,
+			ExitAfterXConsecutiveErr: 1,
+			Cycles:                   1,
+		}.Run,
+	}
+	var wg sync.WaitGroup
+	errC := make(chan error, len(pollers))
+	for _, fn := range pollers {
+		wg.Add(1)
+		go func(fn func(ctx context.Context, d *daemon.Supervisor) error) {
+			defer wg.Done()
+			errC <- fn(ctx, d)
+		}(fn)
+	}
+	wg.Wait()
+	close(errC)
+	for err := range errC {
+		if err != nil {
+			return err
+		}
+	}
+
+	// wait for the store to process every published snapshot
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for !broker.Idle() {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+	c.Settle()
+
+	d.Info("pipeline.complete", report.Data{
+		"snapshots_published": d.Count("snapshot.published"),
+		"batches_published":   d.Count("batch.published"),
+		"snapshots_saved":     d.Count("snapshot.saved"),
+	})
+	p.writeReport(ctx, c)
+	d.Close()
+	return nil
+}
+
+// writeReport lists the level of each calibrated section in slug order
+func (p pipeline) writeReport(ctx context.Context, c *store.Cache) {
+	w := tabwriter.NewWriter(p.Report, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+
+	w.Write([]byte("SECTION\tLEVEL\tREASON\tTIME\n"))
+	for _, s := range rainchasers.Sections {
+		if _, isCalibrated := rainchasers.Calibrations[s.UUID]; !isCalibrated {
+			continue
+		}
+		r, _ := c.Records.Load(ctx, s.UUID)
+		if r == nil {
+			continue
+		}
+		eventTime := "-"
+		if !r.Level.EventTime.IsZero() {
+			eventTime = r.Level.EventTime.UTC().Format(time.RFC3339)
+		}
+		w.Write([]byte(s.Slug + "\t" + r.Level.Label + "\t" + r.Level.Reason + "\t" + eventTime + "\n"))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+)
+
+func TestFixtureReport(t *testing.T) {
+	daemon.Replay("testdata")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	d := daemon.New("example")
+
+	p := pipeline{
+		QueueName: "test-fixture-report",
+		Time:      time.Date(2020, 10, 6, 22, 0, 0, 0, time.UTC),
+		Report:    &bytes.Buffer{},
+	}
+	if err := p.run(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	d.CloseWait()
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// one section per source (nrw, ea, sepa) with known fixture levels
+	report := p.Report.String()
+	expected := map[string][]string{
+		"conwy-fairy-glen":                 {"high", "1.79 at Conwy at Cwmlanerch", "2020-10-06T21:45:00Z"},
+		"rawthey-sedbergh-lune-confluence": {"medium", "1.73 at Sedbergh", "2020-10-06T21:30:00Z"},
+		"esk-borders-langholm-canonbie":    {"low", "1.00 at Canonbie", "2020-10-06T21:45:00Z"},
+	}
+	for slug, fields := range expected {
+		line := findLine(report, slug)
+		if line == "" {
+			t.Error("section missing from report", slug)
+			continue
+		}
+		for _, f := range fields {
+			if !strings.Contains(line, f) {
+				t.Error(slug, "expected", f, "in", line)
+			}
+		}
+	}
+}
+
+func findLine(report string, slug string) string {
+	for _, line := range strings.Split(report, "\n") {
+		if strings.HasPrefix(line, slug+" ") {
+			return line
+		}
+	}
+	return ""
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"html/template"
 	"net/http"
+	"os"
 	"path/filepath"
-	"text/template"
+	"sync"
+	"time"
 
 	"github.com/robtuley/rainchasers"
+	"github.com/robtuley/rainchasers/internal/api"
+	"github.com/robtuley/rainchasers/internal/content"
 	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/history"
 	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/rainchasers/internal/store"
 	"github.com/robtuley/report"
 )
 
@@ -15,7 +23,10 @@ const port = ":8080"
 
 var sectionT *template.Template
 var sectionM map[string]river.Section
+var sections []river.Section
+var sectionMu sync.RWMutex
 var logger *report.Logger
+var records store.RecordStore
 var version string
 
 type homePage struct {
@@ -31,17 +42,70 @@ func init() {
 	f3 := filepath.Join("static", "home.html")
 	sectionT = template.Must(template.ParseFiles(f1, f2, f3))
 
-	sectionM = make(map[string]river.Section, len(rainchasers.Sections))
-	for _, s := range rainchasers.Sections {
-		sectionM["/"+s.Slug] = s
+	setSections(rainchasers.Sections)
+}
+
+// setSections replaces the sections served, as the content may be reloaded
+func setSections(ss []river.Section) {
+	m := make(map[string]river.Section, len(ss))
+	for _, s := range ss {
+		m["/"+s.Slug] = s
 	}
+	sectionMu.Lock()
+	defer sectionMu.Unlock()
+	sectionM = m
+	sections = ss
 }
 
+// Responds to environment variables:
+//   PROJECT_ID (no default, firestore records, this or STORE_URL is required)
+//   STORE_URL (no default, e.g. http://store:8081, a store daemon's HTTP_ADDR serving history and records if no PROJECT_ID)
+//   CONTENT_DIR (no default, e.g. ./rivers watched for changes, blank uses compiled in content)
 func main() {
+	// connect to the river records & reading history, where an empty memory
+	// store would only ever serve unknown levels
+	projectID, storeURL := os.Getenv("PROJECT_ID"), os.Getenv("STORE_URL")
+	if projectID == "" && storeURL == "" {
+		os.Stderr.WriteString("PROJECT_ID or STORE_URL is required for river records\n")
+		os.Exit(1)
+	}
+	var span report.Span
+	if projectID != "" {
+		records, span = store.NewFireWriter(projectID)
+	} else {
+		records, span = store.NewRemoteStore(storeURL)
+	}
+	logger.Trace(span)
+	if err := span.Err(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(1)
+	}
+	defer records.Close()
+	apiHandler := api.New(records, rainchasers.Sections, rainchasers.Calibrations, logger)
+	if storeURL != "" {
+		apiHandler.History = history.NewRemote(storeURL)
+	}
+	if dir := os.Getenv("CONTENT_DIR"); dir != "" {
+		span := report.StartSpan("content.loaded").Field("dir", dir)
+		cn, err := content.Load(dir)
+		logger.Trace(span.End(err))
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+		setContent := func(ctx context.Context, cn *content.Content) {
+			setSections(cn.Sections)
+			apiHandler.SetContent(cn.Sections, cn.Calibrations)
+		}
+		setContent(context.Background(), cn)
+		go content.NewWatcher(dir, setContent).Watch(context.Background(), logger)
+	}
+
 	// setup routes
 	fs := http.FileServer(http.Dir("./static"))
 	http.Handle("/s/", http.StripPrefix("/s/", fs))
 	http.Handle("/favicon.ico", http.NotFoundHandler())
+	http.Handle("/api/", apiHandler)
 	http.HandleFunc("/", serveTemplate)
 
 	// start server
@@ -60,14 +124,38 @@ func main() {
 
 func serveTemplate(w http.ResponseWriter, r *http.Request) {
 	// try to serve a section
+	sectionMu.RLock()
 	s, exists := sectionM[r.URL.Path]
+	ss := sections
+	sectionMu.RUnlock()
 	if exists {
+		// a missing record is shown as an unknown level, but a record store
+		// that cannot be read is unavailable rather than unknown
+		status := http.StatusOK
+		record, span := records.Load(r.Context(), s.UUID)
+		if err := span.Err(); err != nil {
+			logger.Action("record.load", report.Data{
+				"section": s.UUID,
+				"error":   err.Error(),
+			})
+			status = http.StatusServiceUnavailable
+		}
+		if record == nil {
+			record = store.NewRecord(s)
+		}
+		record.Section = s
+		page := newSectionPage(record, time.Now())
+		if status != http.StatusOK {
+			page.Level.Reason = "Live levels are unavailable right now"
+		}
+
 		logger.Info("http.response", report.Data{
-			"status":  200,
+			"status":  status,
 			"path":    r.URL.Path,
 			"section": s.UUID,
 		})
-		sectionT.ExecuteTemplate(w, "section", s)
+		w.WriteHeader(status)
+		sectionT.ExecuteTemplate(w, "section", page)
 		return
 	}
 
@@ -88,6 +176,6 @@ func serveTemplate(w http.ResponseWriter, r *http.Request) {
 	})
 	sectionT.ExecuteTemplate(w, "home", homePage{
 		Version:  version,
-		Sections: rainchasers.Sections,
+		Sections: ss,
 	})
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/rainchasers/internal/store"
+)
+
+const (
+	sparklineWidth  = 240
+	sparklineHeight = 40
+	sparklineWindow = 3 * 24 * time.Hour
+)
+
+type sectionPage struct {
+	river.Section
+	Level    levelView
+	Measures []measureView
+}
+
+type levelView struct {
+	Label  string
+	Reason string
+	Trend  string // blank if unknown
+	Since  string // blank if no reading
+}
+
+type measureView struct {
+	Name      string
+	HumanURL  string
+	Level     levelView
+	Sparkline template.HTMLAttr // SVG polyline points attribute, blank if no readings
+	Min       string
+	Max       string
+}
+
+// newSectionPage presents the latest river record as of now
+func newSectionPage(r *store.Record, now time.Time) sectionPage {
+	p := sectionPage{
+		Section: r.Section,
+		Level:   newLevelView(r.Level, now),
+	}
+	for _, m := range r.Measures {
+		v := measureView{
+			Name:     m.Station.Name,
+			HumanURL: m.Station.HumanURL,
+			Level:    newLevelView(m.CurrentLevel(now, store.DefaultTrendWindow), now),
+		}
+		v.Sparkline, v.Min, v.Max = sparkline(m, now)
+		p.Measures = append(p.Measures, v)
+	}
+	return p
+}
+
+func newLevelView(l store.Level, now time.Time) levelView {
+	v := levelView{
+		Label:  l.Label,
+		Reason: l.Reason,
+	}
+	if l.Trend != store.TrendUnknown {
+		v.Trend = l.Trend
+	}
+	if !l.EventTime.IsZero() {
+		v.Since = timeSince(l.EventTime, now)
+	}
+	return v
+}
+
+// sparkline plots the last 3 days of readings, scaled to their range
+//
+// The points attribute is only ever built from formatted numbers, so is safe
+// to emit unescaped.
+func sparkline(m store.Measure, now time.Time) (points template.HTMLAttr, min string, max string) {
+	from := now.Add(-sparklineWindow)
+	var lo, hi float32
+	n := 0
+	for _, r := range m.Readings {
+		if r.EventTime.Before(from) || r.EventTime.After(now) {
+			continue
+		}
+		if n == 0 || r.Value < lo {
+			lo = r.Value
+		}
+		if n == 0 || r.Value > hi {
+			hi = r.Value
+		}
+		n++
+	}
+	if n == 0 {
+		return "", "", ""
+	}
+
+	// a flat line is drawn through the middle
+	scale := float32(0)
+	if hi > lo {
+		scale = (sparklineHeight - 2) / (hi - lo)
+	}
+
+	// readings are most recent first, so plot in reverse
+	var b strings.Builder
+	for i := len(m.Readings) - 1; i >= 0; i-- {
+		r := m.Readings[i]
+		if r.EventTime.Before(from) || r.EventTime.After(now) {
+			continue
+		}
+		x := float64(r.EventTime.Sub(from)) / float64(sparklineWindow) * sparklineWidth
+		y := float32(sparklineHeight) / 2
+		if scale > 0 {
+			y = sparklineHeight - 1 - (r.Value-lo)*scale
+		}
+		b.WriteString(strconv.FormatFloat(x, 'f', 1, 64) + "," +
+			strconv.FormatFloat(float64(y), 'f', 1, 32) + " ")
+	}
+	min = strconv.FormatFloat(float64(lo), 'f', 2, 32)
+	max = strconv.FormatFloat(float64(hi), 'f', 2, 32)
+	points = template.HTMLAttr(`points="` + strings.TrimSpace(b.String()) + `"`)
+	return points, min, max
+}
+
+// timeSince is a human description of the time since t
+func timeSince(t time.Time, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < 2*time.Minute:
+		return "1 minute ago"
+	case d < time.Hour:
+		return strconv.Itoa(int(d/time.Minute)) + " minutes ago"
+	case d < 2*time.Hour:
+		return "1 hour ago"
+	case d < 48*time.Hour:
+		return strconv.Itoa(int(d/time.Hour)) + " hours ago"
+	}
+	return strconv.Itoa(int(d/(24*time.Hour))) + " days ago"
+}
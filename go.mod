@@ -1,6 +1,6 @@
 module github.com/robtuley/rainchasers
 
-go 1.15
+go 1.16
 
 require (
 	cloud.google.com/go v0.66.0 // indirect
@@ -10,9 +10,11 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/robtuley/report v1.0.0
 	github.com/thingful/osgridconverter v0.0.0-20170127114542-06789ac2e515
+	go.etcd.io/bbolt v1.3.5
 	go.uber.org/goleak v0.10.0
 	golang.org/x/tools v0.0.0-20200918232735-d647fc253266 // indirect
 	google.golang.org/api v0.32.0 // indirect
 	google.golang.org/genproto v0.0.0-20200921151605-7abf4a1a14d5 // indirect
 	google.golang.org/grpc v1.32.0
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
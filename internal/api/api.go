@@ -0,0 +1,490 @@
+// Package api serves river sections, gauge stations and their readings as JSON
+package api
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/history"
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/rainchasers/internal/store"
+	"github.com/robtuley/report"
+)
+
+// Handler serves the JSON API below /api/
+//
+//	/api/stations
+//	/api/stations/{alias}/readings?from=&to=&resolution=
+//	/api/sections/{slug}
+//	/api/sections/{slug}/level
+//
+// A station alias is its alias URL with "://" and "/" replaced by "-",
+// e.g. rloi://5020 is rloi-5020 and rloi://5020/10320 is rloi-5020-10320.
+type Handler struct {
+	Records      store.RecordStore
+	History      history.Querier // nil serves readings from the records only
+	Sections     []river.Section
+	Calibrations map[string][]river.Calibration
+	Log          *report.Logger
+	Now          func() time.Time
+	MaxAge       time.Duration
+	StationsTTL  time.Duration // how long the station index is cached
+
+	mu      sync.RWMutex // guards Sections and Calibrations once serving
+	indexMu sync.Mutex   // guards index, and is held while it is built
+	index   *stationIndex
+}
+
+// stationIndex is the cached stations of every calibrated section, as
+// building it loads every calibrated record
+type stationIndex struct {
+	built        time.Time
+	stations     map[string]*stationJSON // by alias
+	body         []byte                  // JSON list in alias order
+	lastModified time.Time
+}
+
+// New creates a handler for the sections and calibrations
+func New(records store.RecordStore, sections []river.Section, calibrations map[string][]river.Calibration, log *report.Logger) *Handler {
+	return &Handler{
+		Records:      records,
+		Sections:     sections,
+		Calibrations: calibrations,
+		Log:          log,
+		Now:          time.Now,
+		MaxAge:       time.Minute,
+		StationsTTL:  time.Minute,
+	}
+}
+
+// SetContent replaces the sections and calibrations while serving
+func (h *Handler) SetContent(sections []river.Section, calibrations map[string][]river.Calibration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Sections = sections
+	h.Calibrations = calibrations
+
+	h.indexMu.Lock()
+	defer h.indexMu.Unlock()
+	h.index = nil
+}
+
+func (h *Handler) content() ([]river.Section, map[string][]river.Calibration) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Sections, h.Calibrations
+}
+
+// ServeHTTP routes an API request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.error(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "stations":
+		h.serveStations(w, r)
+	case len(parts) == 3 && parts[0] == "stations" && parts[2] == "readings":
+		h.serveReadings(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "sections":
+		h.serveSection(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "sections" && parts[2] == "level":
+		h.serveLevel(w, r, parts[1])
+	default:
+		h.error(w, r, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) serveStations(w http.ResponseWriter, r *http.Request) {
+	index, err := h.stationIndex(r.Context())
+	if err != nil {
+		h.error(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeJSON(w, r, index.body, index.lastModified)
+}
+
+func (h *Handler) serveReadings(w http.ResponseWriter, r *http.Request, alias string) {
+	q := r.URL.Query()
+	to := h.Now()
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.error(w, r, http.StatusBadRequest, "to must be RFC3339")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-3 * 24 * time.Hour)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.error(w, r, http.StatusBadRequest, "from must be RFC3339")
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		h.error(w, r, http.StatusBadRequest, "from must be before to")
+		return
+	}
+	res := history.ResolutionFor(from, to)
+	if v := q.Get("resolution"); v != "" {
+		res = history.Resolution(v)
+		if res.Bucket() == 0 && res != history.Raw {
+			h.error(w, r, http.StatusBadRequest, "resolution must be raw, hourly or daily")
+			return
+		}
+	}
+
+	index, err := h.stationIndex(r.Context())
+	if err != nil {
+		h.error(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s, ok := index.stations[alias]
+	if !ok {
+		h.error(w, r, http.StatusNotFound, "station not found")
+		return
+	}
+
+	var points []history.Point
+	if h.History != nil {
+		var span report.Span
+		points, span = h.History.Query(r.Context(), s.DataURL, res, from, to)
+		if err := span.Err(); err != nil {
+			h.error(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		// without history only the recent raw readings are available
+		res = history.Raw
+		for i := len(s.readings) - 1; i >= 0; i-- {
+			reading := s.readings[i]
+			if !reading.EventTime.Before(from) && reading.EventTime.Before(to) {
+				points = append(points, history.Point{
+					EventTime: reading.EventTime,
+					Value:     reading.Value,
+					Min:       reading.Value,
+					Max:       reading.Value,
+					Count:     1,
+				})
+			}
+		}
+	}
+
+	var lastModified time.Time
+	if len(points) > 0 {
+		lastModified = points[len(points)-1].EventTime
+	}
+	if points == nil {
+		points = make([]history.Point, 0)
+	}
+	h.write(w, r, readingsJSON{
+		Station:    *s,
+		From:       from,
+		To:         to,
+		Resolution: res,
+		Points:     points,
+	}, lastModified)
+}
+
+func (h *Handler) serveSection(w http.ResponseWriter, r *http.Request, slug string) {
+	record, ok := h.record(w, r, slug)
+	if !ok {
+		return
+	}
+
+	measures := make([]measureJSON, 0, len(record.Measures))
+	for _, m := range record.Measures {
+		measures = append(measures, measureJSON{
+			Station:  newStationJSON(m.Station, m.ProcessedTime),
+			Level:    newLevelJSON(m.CurrentLevel(h.Now(), store.DefaultTrendWindow)),
+			Readings: m.Readings,
+		})
+	}
+	h.write(w, r, sectionJSON{
+		UUID:        record.Section.UUID,
+		Slug:        record.Section.Slug,
+		SectionName: record.Section.SectionName,
+		RiverName:   record.Section.RiverName,
+		KM:          record.Section.KM,
+		Grade:       record.Section.Grade.Human,
+		GradeValue:  record.Section.Grade.Average,
+		Putin:       latLngJSON{record.Section.Putin.Lat, record.Section.Putin.Lng},
+		Takeout:     latLngJSON{record.Section.Takeout.Lat, record.Section.Takeout.Lng},
+		Description: record.Section.Description,
+		Directions:  record.Section.Directions,
+		Level:       newLevelJSON(record.Level),
+		Measures:    measures,
+	}, record.Level.ProcessedTime)
+}
+
+func (h *Handler) serveLevel(w http.ResponseWriter, r *http.Request, slug string) {
+	record, ok := h.record(w, r, slug)
+	if !ok {
+		return
+	}
+	h.write(w, r, newLevelJSON(record.Level), record.Level.ProcessedTime)
+}
+
+// record loads the section record, writing an error response if not ok
+func (h *Handler) record(w http.ResponseWriter, r *http.Request, slug string) (*store.Record, bool) {
+	sections, _ := h.content()
+	for _, s := range sections {
+		if s.Slug != slug {
+			continue
+		}
+		record, span := h.Records.Load(r.Context(), s.UUID)
+		if err := span.Err(); err != nil {
+			h.error(w, r, http.StatusInternalServerError, err.Error())
+			return nil, false
+		}
+		if record == nil {
+			record = store.NewRecord(s)
+		}
+		return record, true
+	}
+	h.error(w, r, http.StatusNotFound, "section not found")
+	return nil, false
+}
+
+// stationIndex provides the station index, rebuilding it once older than
+// StationsTTL or when the content changes
+func (h *Handler) stationIndex(ctx context.Context) (*stationIndex, error) {
+	h.indexMu.Lock()
+	defer h.indexMu.Unlock()
+	now := h.Now()
+	if h.index != nil && now.Sub(h.index.built) < h.StationsTTL {
+		return h.index, nil
+	}
+
+	stations, err := h.stations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	aliases := make([]string, 0, len(stations))
+	for alias := range stations {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	index := &stationIndex{built: now, stations: stations}
+	list := make([]stationJSON, 0, len(stations))
+	for _, alias := range aliases {
+		s := stations[alias]
+		list = append(list, *s)
+		if s.ProcessedTime.After(index.lastModified) {
+			index.lastModified = s.ProcessedTime
+		}
+	}
+	index.body, err = json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	h.index = index
+	return index, nil
+}
+
+// stations collects the stations of all calibrated sections by alias
+func (h *Handler) stations(ctx context.Context) (map[string]*stationJSON, error) {
+	sections, calibrations := h.content()
+	uuids := make([]string, 0, len(calibrations))
+	for uuid := range calibrations {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	slugs := make(map[string]string, len(sections))
+	for _, s := range sections {
+		slugs[s.UUID] = s.Slug
+	}
+
+	stations := make(map[string]*stationJSON)
+	for _, uuid := range uuids {
+		record, span := h.Records.Load(ctx, uuid)
+		if err := span.Err(); err != nil {
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+		for _, m := range record.Measures {
+			alias := Alias(m.Station.AliasURL)
+			s, ok := stations[alias]
+			if !ok {
+				sj := newStationJSON(m.Station, m.ProcessedTime)
+				sj.readings = m.Readings
+				s = &sj
+				stations[alias] = s
+			}
+			s.Sections = append(s.Sections, slugs[uuid])
+		}
+	}
+	return stations, nil
+}
+
+// write responds with the JSON value unless the client copy is still fresh
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		h.error(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.writeJSON(w, r, b, lastModified)
+}
+
+// writeJSON responds with encoded JSON unless the client copy is still fresh
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, b []byte, lastModified time.Time) {
+	sum := sha1.Sum(b)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.MaxAge.Seconds())))
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if isNotModified(r, etag, lastModified) {
+		h.log(r, http.StatusNotModified)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", "application/json")
+	h.log(r, http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(b)
+}
+
+// isNotModified checks If-None-Match, or If-Modified-Since if absent
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	h.log(r, code)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func (h *Handler) log(r *http.Request, code int) {
+	if h.Log == nil {
+		return
+	}
+	h.Log.Info("http.response", report.Data{
+		"status": code,
+		"path":   r.URL.Path,
+	})
+}
+
+var aliasReplacer = strings.NewReplacer("://", "-", "/", "-")
+
+// Alias is the API identifier of a station alias URL
+func Alias(aliasURL string) string {
+	return aliasReplacer.Replace(aliasURL)
+}
+
+type stationJSON struct {
+	gauge.Station
+	Alias         string    `json:"alias"`
+	Sections      []string  `json:"sections"`
+	ProcessedTime time.Time `json:"processed_time"`
+
+	readings []gauge.Reading
+}
+
+func newStationJSON(s gauge.Station, processedTime time.Time) stationJSON {
+	return stationJSON{
+		Station:       s,
+		Alias:         Alias(s.AliasURL),
+		Sections:      make([]string, 0),
+		ProcessedTime: processedTime,
+	}
+}
+
+type readingsJSON struct {
+	Station    stationJSON        `json:"station"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Resolution history.Resolution `json:"resolution"`
+	Points     []history.Point    `json:"points"`
+}
+
+type levelJSON struct {
+	Label         string    `json:"label"`
+	Reason        string    `json:"reason"`
+	Trend         string    `json:"trend"`
+	RatePerHour   float32   `json:"rate_per_hour"`
+	Stale         bool      `json:"stale"`
+	EventTime     time.Time `json:"event_time"`
+	ProcessedTime time.Time `json:"processed_time"`
+}
+
+func newLevelJSON(l store.Level) levelJSON {
+	return levelJSON{
+		Label:         l.Label,
+		Reason:        l.Reason,
+		Trend:         l.Trend,
+		RatePerHour:   l.RatePerHour,
+		Stale:         l.Stale,
+		EventTime:     l.EventTime,
+		ProcessedTime: l.ProcessedTime,
+	}
+}
+
+type latLngJSON struct {
+	Lat float32 `json:"lat"`
+	Lng float32 `json:"lng"`
+}
+
+type measureJSON struct {
+	Station  stationJSON     `json:"station"`
+	Level    levelJSON       `json:"level"`
+	Readings []gauge.Reading `json:"readings"`
+}
+
+type sectionJSON struct {
+	UUID        string        `json:"uuid"`
+	Slug        string        `json:"slug"`
+	SectionName string        `json:"section"`
+	RiverName   string        `json:"river"`
+	KM          float32       `json:"km"`
+	Grade       string        `json:"grade"`
+	GradeValue  float32       `json:"grade_numeric"`
+	Putin       latLngJSON    `json:"putin"`
+	Takeout     latLngJSON    `json:"takeout"`
+	Description string        `json:"desc"`
+	Directions  string        `json:"directions"`
+	Level       levelJSON     `json:"level"`
+	Measures    []measureJSON `json:"measures"`
+}
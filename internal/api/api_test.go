@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/history"
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/rainchasers/internal/store"
+	"github.com/robtuley/report"
+)
+
+var eventTime = time.Date(2020, 10, 6, 22, 0, 0, 0, time.UTC)
+
+func testHandler(t *testing.T) *Handler {
+	section := river.Section{
+		UUID:        "aaf61b44-1a20-4f3c-9a97-66b3e6cbc0a5",
+		Slug:        "test-section",
+		SectionName: "Upper",
+		RiverName:   "Test",
+	}
+	station := gauge.Station{
+		DataURL:  "http://example.com/station/1",
+		AliasURL: "rloi://1234",
+		Name:     "Test Gauge",
+	}
+
+	records := store.NewMemoryStore()
+	record := store.NewRecord(section)
+	record.Level = store.Level{
+		EventTime:     eventTime,
+		ProcessedTime: eventTime.Add(time.Minute),
+		Label:         "medium",
+		Reason:        "1.23 at Test Gauge",
+	}
+	record.Measures = append(record.Measures, store.Measure{
+		Station:       station,
+		ProcessedTime: eventTime.Add(time.Minute),
+		Readings: []gauge.Reading{
+			{EventTime: eventTime, Value: 1.23},
+			{EventTime: eventTime.Add(-15 * time.Minute), Value: 1.21},
+		},
+	})
+	records.Store(context.Background(), record)
+
+	h := New(records, []river.Section{section}, map[string][]river.Calibration{
+		section.UUID: {{URL: station.AliasURL}},
+	}, nil)
+	h.Now = func() time.Time { return eventTime.Add(time.Hour) }
+	return h
+}
+
+func get(t *testing.T, h http.Handler, url string, header http.Header, v interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if v != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatal(url, err)
+		}
+	}
+	return w
+}
+
+func TestStationsAndReadings(t *testing.T) {
+	h := testHandler(t)
+
+	var stations []map[string]interface{}
+	w := get(t, h, "/api/stations", nil, &stations)
+	if w.Code != http.StatusOK || len(stations) != 1 {
+		t.Fatal("unexpected stations", w.Code, stations)
+	}
+	if stations[0]["alias"] != "rloi-1234" || stations[0]["data_url"] != "http://example.com/station/1" {
+		t.Error("unexpected station", stations[0])
+	}
+
+	var readings struct {
+		Resolution string          `json:"resolution"`
+		Points     []history.Point `json:"points"`
+	}
+	w = get(t, h, "/api/stations/rloi-1234/readings", nil, &readings)
+	if w.Code != http.StatusOK || len(readings.Points) != 2 || readings.Points[1].Value != 1.23 {
+		t.Fatal("unexpected readings", w.Code, readings)
+	}
+
+	// served from history if available
+	hs, span := history.New(filepath.Join(t.TempDir(), "history.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Close()
+	hs.Append(context.Background(), "http://example.com/station/1", []gauge.Reading{
+		{EventTime: eventTime.Add(-48 * time.Hour), Value: 0.5},
+	})
+	h.History = hs
+	from := eventTime.Add(-30 * 24 * time.Hour).Format(time.RFC3339)
+	w = get(t, h, "/api/stations/rloi-1234/readings?from="+from, nil, &readings)
+	if w.Code != http.StatusOK || readings.Resolution != "hourly" || len(readings.Points) != 1 {
+		t.Fatal("unexpected history readings", w.Code, readings)
+	}
+
+	if w := get(t, h, "/api/stations/rloi-1234/readings?from=yesterday", nil, nil); w.Code != http.StatusBadRequest {
+		t.Error("expected bad request, got", w.Code)
+	}
+	if w := get(t, h, "/api/stations/sepa-1/readings", nil, nil); w.Code != http.StatusNotFound {
+		t.Error("expected not found, got", w.Code)
+	}
+}
+
+// countingStore counts the records loaded
+type countingStore struct {
+	store.RecordStore
+	nLoads int
+}
+
+func (cs *countingStore) Load(ctx context.Context, uuid string) (*store.Record, report.Span) {
+	cs.nLoads++
+	return cs.RecordStore.Load(ctx, uuid)
+}
+
+func TestStationIndexIsCached(t *testing.T) {
+	h := testHandler(t)
+	records := &countingStore{RecordStore: h.Records}
+	h.Records = records
+	now := eventTime.Add(time.Hour)
+	h.Now = func() time.Time { return now }
+
+	first := get(t, h, "/api/stations", nil, nil)
+	get(t, h, "/api/stations/rloi-1234/readings", nil, nil)
+	second := get(t, h, "/api/stations", nil, nil)
+	if records.nLoads != 1 {
+		t.Error("expected one record load, got", records.nLoads)
+	}
+	if first.Header().Get("ETag") != second.Header().Get("ETag") {
+		t.Error("expected the same etag from the cached index")
+	}
+
+	// rebuilt once expired, or the content is reloaded
+	now = now.Add(h.StationsTTL)
+	get(t, h, "/api/stations", nil, nil)
+	if records.nLoads != 2 {
+		t.Error("expected expired index to be rebuilt, got", records.nLoads)
+	}
+	h.SetContent(h.Sections, h.Calibrations)
+	get(t, h, "/api/stations", nil, nil)
+	if records.nLoads != 3 {
+		t.Error("expected reloaded content to rebuild the index, got", records.nLoads)
+	}
+}
+
+func TestSectionAndLevel(t *testing.T) {
+	h := testHandler(t)
+
+	var section struct {
+		Slug     string                   `json:"slug"`
+		Measures []map[string]interface{} `json:"measures"`
+	}
+	w := get(t, h, "/api/sections/test-section", nil, &section)
+	if w.Code != http.StatusOK || section.Slug != "test-section" || len(section.Measures) != 1 {
+		t.Fatal("unexpected section", w.Code, section)
+	}
+
+	var level levelJSON
+	w = get(t, h, "/api/sections/test-section/level", nil, &level)
+	if w.Code != http.StatusOK || level.Label != "medium" || !level.EventTime.Equal(eventTime) {
+		t.Fatal("unexpected level", w.Code, level)
+	}
+
+	if w := get(t, h, "/api/sections/missing", nil, nil); w.Code != http.StatusNotFound {
+		t.Error("expected not found, got", w.Code)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	h := testHandler(t)
+
+	w := get(t, h, "/api/sections/test-section/level", nil, nil)
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	if etag == "" || lastModified != "Tue, 06 Oct 2020 22:01:00 GMT" {
+		t.Fatal("expected cache headers", w.Header())
+	}
+
+	w = get(t, h, "/api/sections/test-section/level", http.Header{"If-None-Match": {etag}}, nil)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Error("expected not modified for etag, got", w.Code)
+	}
+	w = get(t, h, "/api/sections/test-section/level", http.Header{"If-None-Match": {`"other"`}}, nil)
+	if w.Code != http.StatusOK {
+		t.Error("expected ok for stale etag, got", w.Code)
+	}
+	w = get(t, h, "/api/sections/test-section/level", http.Header{"If-Modified-Since": {lastModified}}, nil)
+	if w.Code != http.StatusNotModified {
+		t.Error("expected not modified since, got", w.Code)
+	}
+}
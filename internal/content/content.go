@@ -0,0 +1,157 @@
+// Package content loads the river sections and calibrations of rivers/*.yaml
+package content
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+// Content is the set of river sections and their calibrations
+type Content struct {
+	Sections     []river.Section
+	Calibrations map[string][]river.Calibration // keyed by section UUID
+	Warnings     Errors                         // suspicious but loadable values
+
+	byUUID map[string]int
+	bySlug map[string]int
+}
+
+// New indexes sections and calibrations, e.g. those compiled into rivers.go
+func New(sections []river.Section, calibrations map[string][]river.Calibration) *Content {
+	c := &Content{
+		Sections:     sections,
+		Calibrations: calibrations,
+		byUUID:       make(map[string]int, len(sections)),
+		bySlug:       make(map[string]int, len(sections)),
+	}
+	for i, s := range sections {
+		c.byUUID[s.UUID] = i
+		c.bySlug[s.Slug] = i
+	}
+	return c
+}
+
+// Load reads every *.yaml section file in dir, e.g. ./rivers
+func Load(dir string) (*Content, error) {
+	return load(os.DirFS(dir), dir)
+}
+
+// LoadFS reads every *.yaml section file at the root of fsys
+func LoadFS(fsys fs.FS) (*Content, error) {
+	return load(fsys, "")
+}
+
+// load reads and validates each file, collecting the problems of every file
+// so they can all be fixed at once, where dir prefixes the file names of errors
+func load(fsys fs.FS, dir string) (*Content, error) {
+	files, err := fs.Glob(fsys, "*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var sections []river.Section
+	calibrations := make(map[string][]river.Calibration)
+	var errs, warnings Errors
+	fileOfUUID := make(map[string]string)
+	for _, fn := range files {
+		b, err := fs.ReadFile(fsys, fn)
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Join(dir, fn)
+		f, root, fileErrs := parse(name, b)
+		errs = append(errs, fileErrs...)
+		if f == nil {
+			continue
+		}
+		fileErrs, fileWarnings := validate(name, root, f)
+		errs = append(errs, fileErrs...)
+		warnings = append(warnings, fileWarnings...)
+		if other, ok := fileOfUUID[f.UUID]; ok && f.UUID != "" {
+			errs = append(errs, &Error{
+				File:  name,
+				Line:  lineOf(root, "uuid"),
+				Field: "uuid",
+				Err:   fmt.Errorf("duplicate uuid %s of %s", f.UUID, other),
+			})
+		}
+		fileOfUUID[f.UUID] = name
+
+		s := f.Section
+		s.Slug = strings.TrimSuffix(path.Base(fn), ".yaml")
+		sections = append(sections, s)
+		for _, yc := range f.Measures {
+			calibrations[s.UUID] = append(calibrations[s.UUID], yc.calibration())
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	c := New(sections, calibrations)
+	c.Warnings = warnings
+	return c, nil
+}
+
+// Section finds a section by UUID
+func (c *Content) Section(uuid string) (river.Section, bool) {
+	i, ok := c.byUUID[uuid]
+	if !ok {
+		return river.Section{}, false
+	}
+	return c.Sections[i], true
+}
+
+// SectionBySlug finds a section by slug, e.g. aire-wave
+func (c *Content) SectionBySlug(slug string) (river.Section, bool) {
+	i, ok := c.bySlug[slug]
+	if !ok {
+		return river.Section{}, false
+	}
+	return c.Sections[i], true
+}
+
+// CalibratedURLs are the distinct gauge URLs used to calibrate any section
+func (c *Content) CalibratedURLs() []string {
+	isSeen := make(map[string]bool)
+	var urls []string
+	for _, calibrations := range c.Calibrations {
+		for _, cal := range calibrations {
+			if !isSeen[cal.URL] {
+				isSeen[cal.URL] = true
+				urls = append(urls, cal.URL)
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Stamp identifies the current state of the *.yaml files in dir, and
+// changes whenever a file is added, removed or modified
+func Stamp(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	for _, fn := range files {
+		info, err := os.Stat(fn)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(fn + " " + strconv.FormatInt(info.Size(), 10) + " " +
+			strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
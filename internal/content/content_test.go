@@ -0,0 +1,116 @@
+package content
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Sections) != 2 || c.Sections[0].Slug != "calibrated" || c.Sections[1].Slug != "uncalibrated" {
+		t.Fatal("unexpected sections", c.Sections)
+	}
+	cals := c.Calibrations["46991772-f124-4c7f-8ad2-187108d249bf"]
+	if len(cals) != 1 || cals[0].URL != "rloi://8079" {
+		t.Fatal("unexpected calibrations", c.Calibrations)
+	}
+	if cals[0].Minimum[river.Medium.String()] != 1.3 || len(cals[0].Minimum) != 4 {
+		t.Error("unexpected thresholds", cals[0].Minimum)
+	}
+	if _, ok := c.Calibrations["7f0c4ac0-5b1e-4a3c-9d0e-3c2f2a9b7d11"]; ok {
+		t.Error("unexpected calibration of uncalibrated section")
+	}
+}
+
+func TestWatcherReloadsChanges(t *testing.T) {
+	dir := t.TempDir()
+	b, err := ioutil.ReadFile("testdata/calibrated.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(dir, "calibrated.yaml")
+	if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed := make(chan *Content)
+	w := NewWatcher(dir, func(ctx context.Context, c *Content) {
+		changed <- c
+	})
+	w.Every = 10 * time.Millisecond
+	go w.Watch(ctx, report.New("test"))
+
+	// a file that does not parse is ignored
+	if err := ioutil.WriteFile(fn, []byte("uuid: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// a new file is loaded
+	if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fn, time.Now(), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changed:
+		if len(c.Sections) != 1 {
+			t.Error("unexpected sections", c.Sections)
+		}
+	case <-ctx.Done():
+		t.Fatal("change not reloaded")
+	}
+}
+
+func TestLoadReportsEveryFileAndField(t *testing.T) {
+	_, err := Load("testdata/invalid")
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("expected an error per file", err)
+	}
+	if errs[0].File != filepath.Join("testdata", "invalid", "bad-threshold.yaml") ||
+		errs[0].Line != 17 || errs[0].Field != "measures[0].medium" {
+		t.Error("unexpected error location", errs[0])
+	}
+	if errs[1].File != filepath.Join("testdata", "invalid", "unclosed.yaml") || errs[1].Line == 0 {
+		t.Error("unexpected error location", errs[1])
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/calibrated.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadFS(fstest.MapFS{
+		"calibrated.yaml": &fstest.MapFile{Data: b},
+		"README.md":       &fstest.MapFile{Data: []byte("not content")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := c.SectionBySlug("calibrated")
+	if !ok || s.UUID != "46991772-f124-4c7f-8ad2-187108d249bf" {
+		t.Fatal("section not indexed by slug", c.Sections)
+	}
+	if _, ok := c.Section(s.UUID); !ok {
+		t.Error("section not indexed by uuid")
+	}
+	if urls := c.CalibratedURLs(); len(urls) != 1 || urls[0] != "rloi://8079" {
+		t.Error("unexpected calibrated urls", urls)
+	}
+}
@@ -0,0 +1,205 @@
+package content
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"gopkg.in/yaml.v3"
+)
+
+// DataURLSchemes are the gauge data URL schemes a calibration can reference
+var DataURLSchemes = []string{"rloi", "sepa", "sepa-rainfall", "http", "https"}
+
+// ukBounds is a box around the UK, from Scilly to Shetland and Fermanagh to
+// Lowestoft, that every putin and takeout should be inside
+var ukBounds = struct{ South, North, West, East float32 }{49.8, 60.9, -8.2, 1.8}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// validation collects the problems of a single file
+type validation struct {
+	file     string
+	root     *yaml.Node
+	errs     Errors
+	warnings Errors
+}
+
+func (v *validation) error(field string, format string, args ...interface{}) {
+	v.errs = append(v.errs, v.problem(field, format, args...))
+}
+
+func (v *validation) warn(field string, format string, args ...interface{}) {
+	v.warnings = append(v.warnings, v.problem(field, format, args...))
+}
+
+func (v *validation) problem(field string, format string, args ...interface{}) *Error {
+	return &Error{
+		File:  v.file,
+		Line:  lineOf(v.root, field),
+		Field: field,
+		Err:   fmt.Errorf(format, args...),
+	}
+}
+
+// validate checks a decoded section file is complete and plausible, where
+// errors fail the load and warnings are suspicious values worth a look
+func validate(fn string, root *yaml.Node, f *yamlFile) (errs Errors, warnings Errors) {
+	v := &validation{file: fn, root: root}
+
+	switch {
+	case f.UUID == "":
+		v.error("uuid", "missing uuid")
+	case !uuidRegexp.MatchString(f.UUID):
+		v.error("uuid", "%q is not a lowercase uuid", f.UUID)
+	}
+	if f.RiverName == "" {
+		v.error("river", "missing river name")
+	}
+	if f.SectionName == "" {
+		v.error("section", "missing section name")
+	}
+	if f.Strategy != "" && !isStrategy(f.Strategy) {
+		v.error("level_strategy", "unknown strategy %q", f.Strategy)
+	}
+	if f.Grade.Average != 0 && (f.Grade.Average < 1 || f.Grade.Average > 6) {
+		v.warn("grade.value", "grade %v is outside 1 to 6", f.Grade.Average)
+	}
+
+	isLocated := v.location("putin", f.Putin)
+	isLocated = v.location("takeout", f.Takeout) && isLocated
+	if isLocated && f.KM > 0 {
+		// a river cannot be shorter than the straight line along it
+		if d := distance(f.Putin, f.Takeout); d > 1.1*float64(f.KM)+1 {
+			v.warn("km", "%vkm section but putin is %.1fkm from takeout", f.KM, d)
+		}
+	}
+
+	for i, m := range f.Measures {
+		v.calibration("measures["+strconv.Itoa(i)+"]", m)
+	}
+	return v.errs, v.warnings
+}
+
+// location checks a putin or takeout is in the UK, and is true if it is
+func (v *validation) location(field string, l river.LatLng) bool {
+	if l.Lat == 0 && l.Lng == 0 {
+		v.warn(field, "missing location")
+		return false
+	}
+	if l.Lat < ukBounds.South || l.Lat > ukBounds.North || l.Lng < ukBounds.West || l.Lng > ukBounds.East {
+		v.error(field, "%v,%v is outside the UK", l.Lat, l.Lng)
+		return false
+	}
+	return true
+}
+
+func (v *validation) calibration(field string, m yamlCalibration) {
+	if m.URL == "" {
+		v.error(field+".data_url", "missing data_url")
+	} else if !isDataURL(m.URL) {
+		v.error(field+".data_url", "unknown scheme of %q, expected one of %s",
+			m.URL, strings.Join(DataURLSchemes, ", "))
+	}
+	if m.Weight < 0 {
+		v.error(field+".weight", "negative weight %v", m.Weight)
+	}
+
+	// each level threshold must be above those of the lower levels
+	thresholds := []struct {
+		name  string
+		value *float32
+	}{
+		{"scrape", m.Scrape},
+		{"low", m.Low},
+		{"medium", m.Medium},
+		{"high", m.High},
+		{"huge", m.Huge},
+		{"toohigh", m.TooHigh},
+	}
+	prev := -1
+	for i, t := range thresholds {
+		if t.value == nil {
+			continue
+		}
+		if prev >= 0 && *t.value <= *thresholds[prev].value {
+			v.error(field+"."+t.name, "%s %v is not above %s %v",
+				t.name, *t.value, thresholds[prev].name, *thresholds[prev].value)
+		}
+		prev = i
+	}
+	if prev < 0 {
+		v.warn(field, "no level thresholds")
+	}
+}
+
+func isStrategy(s river.Strategy) bool {
+	for _, valid := range river.Strategies {
+		if s == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func isDataURL(url string) bool {
+	i := strings.Index(url, "://")
+	if i < 1 || i+3 == len(url) {
+		return false
+	}
+	for _, scheme := range DataURLSchemes {
+		if url[:i] == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// distance is the great circle distance in km between two locations
+func distance(a, b river.LatLng) float64 {
+	const earthRadius = 6371.0
+	rad := func(deg float32) float64 { return float64(deg) * math.Pi / 180 }
+	dLat := rad(b.Lat - a.Lat)
+	dLng := rad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(a.Lat))*math.Cos(rad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// lineOf finds the line of a field path such as measures[0].low, or of the
+// nearest parent that is present
+func lineOf(root *yaml.Node, field string) int {
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	line := n.Line
+	for _, part := range strings.Split(strings.ReplaceAll(field, "[", ".["), ".") {
+		var next *yaml.Node
+		switch {
+		case part == "":
+			continue
+		case n.Kind == yaml.SequenceNode && strings.HasPrefix(part, "["):
+			i, err := strconv.Atoi(strings.Trim(part, "[]"))
+			if err == nil && i < len(n.Content) {
+				next = n.Content[i]
+				line = next.Line
+			}
+		case n.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == part {
+					line = n.Content[i].Line
+					next = n.Content[i+1]
+				}
+			}
+		}
+		if next == nil {
+			return line
+		}
+		n = next
+	}
+	return line
+}
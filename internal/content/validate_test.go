@@ -0,0 +1,113 @@
+package content
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+const validSection = `uuid: 46991772-f124-4c7f-8ad2-187108d249bf
+river: Aire
+section: Wave
+km: 1
+putin:
+  lat: 53.7484432
+  lng: -1.4224225
+takeout:
+  lat: 53.7484432
+  lng: -1.4224225
+measures:
+  - data_url: 'rloi://8079'
+    low: 1.1
+    medium: 1.3
+`
+
+func loadProblems(t *testing.T, files map[string]string) (errs Errors, warnings Errors) {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for fn, y := range files {
+		fsys[fn] = &fstest.MapFile{Data: []byte(y)}
+	}
+	c, err := LoadFS(fsys)
+	if err == nil {
+		return nil, c.Warnings
+	}
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatal("unexpected error", err)
+	}
+	return errs, nil
+}
+
+func TestValidSectionHasNoProblems(t *testing.T) {
+	errs, warnings := loadProblems(t, map[string]string{"a.yaml": validSection})
+	if len(errs) > 0 || len(warnings) > 0 {
+		t.Error("unexpected problems", errs, warnings)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	errs, _ := loadProblems(t, map[string]string{"a.yaml": `river: Aire
+section: Wave
+putin:
+  lat: 48.1
+  lng: -1.4
+takeout:
+  lat: 53.7
+  lng: -1.4
+measures:
+  - data_url: 'ftp://8079'
+    scrape: 1.2
+    low: 1.1
+    toohihg: 2
+`})
+	expected := []struct {
+		line  int
+		field string
+	}{
+		{13, "measures[0].toohihg"},
+		{1, "uuid"},
+		{3, "putin"},
+		{10, "measures[0].data_url"},
+		{12, "measures[0].low"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatal("expected an error per problem", errs)
+	}
+	for i, e := range expected {
+		if errs[i].Line != e.line || errs[i].Field != e.field {
+			t.Errorf("expected %s on line %d, got %v", e.field, e.line, errs[i])
+		}
+	}
+	if errs[0].Error() != "a.yaml:13: measures[0].toohihg: unknown field toohihg" {
+		t.Error("unexpected message", errs[0])
+	}
+}
+
+func TestValidateDuplicateUUID(t *testing.T) {
+	errs, _ := loadProblems(t, map[string]string{
+		"a.yaml": validSection,
+		"b.yaml": validSection,
+	})
+	if len(errs) != 1 || errs[0].File != "b.yaml" || errs[0].Field != "uuid" || errs[0].Line != 1 {
+		t.Error("expected duplicate uuid in second file", errs)
+	}
+}
+
+func TestValidateWarnsOfSuspiciousValues(t *testing.T) {
+	_, warnings := loadProblems(t, map[string]string{"a.yaml": `uuid: 46991772-f124-4c7f-8ad2-187108d249bf
+river: Aire
+section: Wave
+km: 2
+putin:
+  lat: 53.7
+  lng: -1.4
+takeout:
+  lat: 53.8
+  lng: -1.4
+measures:
+  - data_url: 'rloi://8079'
+`})
+	if len(warnings) != 2 || warnings[0].Field != "km" || warnings[1].Field != "measures[0]" {
+		t.Error("expected distance and threshold warnings", warnings)
+	}
+}
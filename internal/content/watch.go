@@ -0,0 +1,73 @@
+package content
+
+import (
+	"context"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/report"
+)
+
+// DefaultWatchEvery is how often the content directory is checked for changes
+const DefaultWatchEvery = 10 * time.Second
+
+// Watcher reloads the content directory whenever its files change
+//
+// Content that fails to load is logged and ignored, so the last good
+// content stays in use until the files are fixed.
+type Watcher struct {
+	Dir      string
+	Every    time.Duration
+	OnChange func(ctx context.Context, c *Content)
+
+	stamp string
+}
+
+// NewWatcher creates a watcher of dir, where the content already in use was
+// loaded from the files as they are now
+func NewWatcher(dir string, onChange func(ctx context.Context, c *Content)) *Watcher {
+	stamp, _ := Stamp(dir)
+	return &Watcher{
+		Dir:      dir,
+		Every:    DefaultWatchEvery,
+		OnChange: onChange,
+		stamp:    stamp,
+	}
+}
+
+// Run checks for changes as a supervised daemon process
+func (w *Watcher) Run(ctx context.Context, d *daemon.Supervisor) error {
+	w.Watch(ctx, d.Logger)
+	return nil
+}
+
+// Watch checks for changes until the context is cancelled
+func (w *Watcher) Watch(ctx context.Context, log *report.Logger) {
+	ticker := time.NewTicker(w.Every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stamp, err := Stamp(w.Dir)
+		if err != nil || stamp == w.stamp {
+			continue
+		}
+		w.stamp = stamp
+
+		span := report.StartSpan("content.reloaded").Field("dir", w.Dir)
+		c, err := Load(w.Dir)
+		if err != nil {
+			log.Trace(span.End(err))
+			continue
+		}
+		span = span.Field("sections_count", len(c.Sections))
+		span = span.Field("calibrated_count", len(c.Calibrations))
+		span = span.Field("warnings_count", len(c.Warnings))
+		log.Trace(span.End())
+		w.OnChange(ctx, c)
+	}
+}
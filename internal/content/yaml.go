@@ -0,0 +1,190 @@
+package content
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlFile is the layout of a rivers/*.yaml section file
+type yamlFile struct {
+	river.Section `yaml:",inline"`
+	Measures      []yamlCalibration `yaml:"measures"`
+}
+
+type yamlCalibration struct {
+	URL         string   `yaml:"data_url"`
+	Description string   `yaml:"desc"`
+	Priority    int      `yaml:"priority,omitempty"`
+	Weight      float32  `yaml:"weight,omitempty"`
+	Scrape      *float32 `yaml:"scrape,omitempty"`
+	Low         *float32 `yaml:"low,omitempty"`
+	Medium      *float32 `yaml:"medium,omitempty"`
+	High        *float32 `yaml:"high,omitempty"`
+	Huge        *float32 `yaml:"huge,omitempty"`
+	TooHigh     *float32 `yaml:"toohigh,omitempty"`
+}
+
+func (yc yamlCalibration) calibration() river.Calibration {
+	c := river.Calibration{
+		URL:         yc.URL,
+		Description: yc.Description,
+		Priority:    yc.Priority,
+		Weight:      yc.Weight,
+		Minimum:     make(map[string]float32),
+	}
+	if yc.Scrape != nil {
+		c.Minimum[river.Scrape.String()] = *yc.Scrape
+	}
+	if yc.Low != nil {
+		c.Minimum[river.Low.String()] = *yc.Low
+	}
+	if yc.Medium != nil {
+		c.Minimum[river.Medium.String()] = *yc.Medium
+	}
+	if yc.High != nil {
+		c.Minimum[river.High.String()] = *yc.High
+	}
+	if yc.Huge != nil {
+		c.Minimum[river.Huge.String()] = *yc.Huge
+	}
+	if yc.TooHigh != nil {
+		c.Minimum[river.TooHigh.String()] = *yc.TooHigh
+	}
+	return c
+}
+
+// Error is a problem with a river content file, e.g.
+// rivers/aire-wave.yaml:17: measures[0].low: cannot unmarshal !!str `high` into float32
+type Error struct {
+	File  string
+	Line  int    // zero if not known
+	Field string // path such as measures[0].low, blank for the whole file
+	Err   error
+}
+
+func (e *Error) Error() string {
+	msg := e.File
+	if e.Line > 0 {
+		msg += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Field != "" {
+		msg += ": " + e.Field
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Errors are all the problems found loading the content
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+var lineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// splitLine separates the line number from a yaml error message
+func splitLine(msg string) (int, string) {
+	m := lineRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, msg
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line, msg[len(m[0]):]
+}
+
+var unknownFieldRegexp = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+
+// parse strictly decodes a section file, or nil if any value cannot be decoded
+func parse(fn string, b []byte) (*yamlFile, *yaml.Node, Errors) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		line, msg := splitLine(err.Error())
+		return nil, nil, Errors{{File: fn, Line: line, Err: errors.New(msg)}}
+	}
+
+	f := &yamlFile{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	err := dec.Decode(f)
+	if err == nil || errors.Is(err, io.EOF) {
+		return f, &root, nil
+	}
+
+	// report each field that failed to decode
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		line, msg := splitLine(err.Error())
+		return nil, nil, Errors{{File: fn, Line: line, Err: errors.New(msg)}}
+	}
+	var errs Errors
+	isUnknownFields := true
+	for _, e := range typeErr.Errors {
+		line, msg := splitLine(e)
+		if m := unknownFieldRegexp.FindStringSubmatch(msg); m != nil {
+			msg = "unknown field " + m[1]
+		} else {
+			isUnknownFields = false
+		}
+		errs = append(errs, &Error{
+			File:  fn,
+			Line:  line,
+			Field: fieldAt(&root, line),
+			Err:   errors.New(msg),
+		})
+	}
+
+	// a value that failed to decode is left zero, so cannot be validated
+	if !isUnknownFields {
+		return nil, nil, errs
+	}
+	return f, &root, errs
+}
+
+// fieldAt finds the path of the field on a line of a yaml document, e.g.
+// measures[0].low
+func fieldAt(n *yaml.Node, line int) string {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) > 0 {
+			return fieldAt(n.Content[0], line)
+		}
+	case yaml.MappingNode:
+		// the field is the last key that starts on or before the line
+		for i := len(n.Content) - 2; i >= 0; i -= 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Line > line {
+				continue
+			}
+			if sub := fieldAt(v, line); sub != "" {
+				if strings.HasPrefix(sub, "[") {
+					return k.Value + sub
+				}
+				return k.Value + "." + sub
+			}
+			return k.Value
+		}
+	case yaml.SequenceNode:
+		for i := len(n.Content) - 1; i >= 0; i-- {
+			if n.Content[i].Line > line {
+				continue
+			}
+			path := "[" + strconv.Itoa(i) + "]"
+			if sub := fieldAt(n.Content[i], line); sub != "" {
+				return path + "." + sub
+			}
+			return path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var unsafeFixtureChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// FixtureName provides the filename a URL is recorded to or replayed from
+//
+// e.g. http://example.com/a/b?c=d is example.com_a_b_c_d
+func FixtureName(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	return unsafeFixtureChars.ReplaceAllString(url, "_")
+}
+
+// Replay serves all subsequent HTTP requests from fixture files in dir
+//
+// A request without a fixture file receives a 404 Not Found response.
+func Replay(dir string) {
+	httpDefaultClient.Transport = replayTransport(dir)
+}
+
+// Record saves all subsequent successful HTTP responses as fixture files in dir
+func Record(dir string) {
+	httpDefaultClient.Transport = recordTransport{
+		dir:  dir,
+		next: http.DefaultTransport,
+	}
+}
+
+type replayTransport string
+
+func (dir replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fn := filepath.Join(string(dir), FixtureName(req.URL.String()))
+	body, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return fixtureResponse(req, http.StatusNotFound, nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fixtureResponse(req, http.StatusOK, body), nil
+}
+
+type recordTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func (t recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	fn := filepath.Join(t.dir, FixtureName(req.URL.String()))
+	if err := ioutil.WriteFile(fn, body, 0644); err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func fixtureResponse(req *http.Request, code int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -36,6 +36,29 @@ func JSON(ctx context.Context, url string) (*http.Response, error) {
 	return resp, nil
 }
 
+// AuthorisedJSON makes a request for JSON data from an API that expects
+// a key in the header
+func AuthorisedJSON(ctx context.Context, url string, keyHeader string, key string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("User-Agent", httpUserAgent)
+	req.Header.Set(keyHeader, key)
+
+	resp, err := httpDefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, errors.New("Status code " + strconv.Itoa(resp.StatusCode) + " for " + url)
+	}
+
+	return resp, nil
+}
+
 // CSV makes a request for a CSV file content
 func CSV(ctx context.Context, url string) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
@@ -55,3 +78,44 @@ func CSV(ctx context.Context, url string) (*http.Response, error) {
 
 	return resp, nil
 }
+
+// Validators identify the content of a response so that a later request
+// can be made conditional on it having changed
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// ConditionalCSV makes a request for CSV file content unless it is
+// unchanged since the validators, in which case the response is
+// 304 Not Modified with no error
+func ConditionalCSV(ctx context.Context, url string, v Validators) (*http.Response, Validators, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, v, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", httpUserAgent)
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, err := httpDefaultClient.Do(req)
+	if err != nil {
+		return nil, v, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, v, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, v, errors.New("Status code " + strconv.Itoa(resp.StatusCode) + " for " + url)
+	}
+
+	return resp, Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
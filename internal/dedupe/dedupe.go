@@ -0,0 +1,206 @@
+// Package dedupe stops pollers republishing readings that are unchanged
+package dedupe
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/report"
+)
+
+// DefaultSaveEvery is the minimum period between writes of the state file
+const DefaultSaveEvery = time.Minute
+
+// Filter remembers the last published reading time of each station DataURL
+// so only newer readings are published
+//
+// The state is saved to a small JSON file so it survives a restart, or is
+// held in memory only if the path is blank.
+type Filter struct {
+	Path      string
+	SaveEvery time.Duration
+
+	mu      sync.Mutex
+	last    map[string]time.Time
+	isDirty bool
+	savedAt time.Time
+}
+
+// New loads the filter state from the file at path if it exists
+func New(path string) (*Filter, report.Span) {
+	span := report.StartSpan("dedupe.loaded").Field("path", path)
+	f := &Filter{
+		Path:      path,
+		SaveEvery: DefaultSaveEvery,
+		last:      make(map[string]time.Time),
+		savedAt:   time.Now(),
+	}
+	if path == "" {
+		return f, span.End()
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, span.End()
+	}
+	if err != nil {
+		return nil, span.End(err)
+	}
+	if err := json.Unmarshal(b, &f.last); err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("stations_count", len(f.last))
+	return f, span.End()
+}
+
+// Readings provides the snapshot readings newer than the last published
+func (f *Filter) Readings(s *gauge.Snapshot) []gauge.Reading {
+	f.mu.Lock()
+	last, ok := f.last[s.Station.DataURL]
+	f.mu.Unlock()
+	if !ok {
+		return s.Readings
+	}
+
+	var readings []gauge.Reading
+	for _, r := range s.Readings {
+		if r.EventTime.After(last) {
+			readings = append(readings, r)
+		}
+	}
+	return readings
+}
+
+// Published records the latest reading time of a published snapshot
+func (f *Filter) Published(s *gauge.Snapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	last := f.last[s.Station.DataURL]
+	for _, r := range s.Readings {
+		if r.EventTime.After(last) {
+			last = r.EventTime
+			f.isDirty = true
+		}
+	}
+	f.last[s.Station.DataURL] = last
+}
+
+// Publish sends only the snapshot readings that are new to the topic, with
+// a snapshot.unchanged span if there are none
+func (f *Filter) Publish(ctx context.Context, topic *queue.Topic, s *gauge.Snapshot) report.Span {
+	readings := f.Readings(s)
+	if len(readings) == 0 {
+		return report.StartSpan("snapshot.unchanged").
+			Field("station", s.Station.AliasURL).
+			Field("count_readings", len(s.Readings)).
+			End()
+	}
+
+	s.Readings = readings
+	span := topic.Publish(ctx, s)
+	if span.Err() != nil {
+		return span
+	}
+	f.Published(s)
+
+	if f.isSaveDue() {
+		span = span.FollowedBy(f.Save())
+	}
+	return span
+}
+
+// PublishBatch sends the snapshots with new readings to the topic as a
+// single batch, with a snapshot.unchanged span if there are none
+func (f *Filter) PublishBatch(ctx context.Context, topic *queue.Topic, snapshots []*gauge.Snapshot) report.Span {
+	var changed []*gauge.Snapshot
+	for _, s := range snapshots {
+		if readings := f.Readings(s); len(readings) > 0 {
+			s.Readings = readings
+			changed = append(changed, s)
+		}
+	}
+	if len(changed) == 0 {
+		return report.StartSpan("snapshot.unchanged").
+			Field("count_snapshots", len(snapshots)).
+			End()
+	}
+
+	span := topic.PublishBatch(ctx, changed)
+	if span.Err() != nil {
+		return span
+	}
+	for _, s := range changed {
+		f.Published(s)
+	}
+
+	if f.isSaveDue() {
+		span = span.FollowedBy(f.Save())
+	}
+	return span
+}
+
+func (f *Filter) isSaveDue() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Path != "" && f.isDirty && time.Since(f.savedAt) >= f.SaveEvery
+}
+
+// Save writes the state file if there are changes since the last save
+func (f *Filter) Save() report.Span {
+	span := report.StartSpan("dedupe.saved").Field("path", f.Path)
+
+	f.mu.Lock()
+	if f.Path == "" || !f.isDirty {
+		f.mu.Unlock()
+		return span.End()
+	}
+	b, err := json.Marshal(f.last)
+	f.isDirty = false
+	f.savedAt = time.Now()
+	n := len(f.last)
+	f.mu.Unlock()
+	if err == nil {
+		err = writeFile(f.Path, b)
+	}
+	if err != nil {
+		// keep the changes to retry on the next save
+		f.mu.Lock()
+		f.isDirty = true
+		f.mu.Unlock()
+		return span.End(err)
+	}
+
+	span = span.Field("stations_count", n)
+	return span.End()
+}
+
+// writeFile writes to a temporary file then renames it, so a crash never
+// leaves a partial state file
+func writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package dedupe
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+func snapshot(times ...time.Time) *gauge.Snapshot {
+	s := &gauge.Snapshot{
+		Station: gauge.Station{DataURL: "http://example.com/1", AliasURL: "rloi://1"},
+	}
+	for _, t := range times {
+		s.Readings = append(s.Readings, gauge.Reading{EventTime: t, Value: 1})
+	}
+	return s
+}
+
+func TestPublishOnlyNewReadings(t *testing.T) {
+	t0 := time.Date(2020, 10, 6, 20, 0, 0, 0, time.UTC)
+	t1 := t0.Add(15 * time.Minute)
+
+	f, span := New("")
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	broker := queue.NewMemory()
+	broker.CreateGroup("test")
+	topic := queue.NewTopic(broker)
+	ctx := context.Background()
+
+	d := daemon.New("test")
+	defer d.CloseWait()
+	for _, s := range []*gauge.Snapshot{snapshot(t0), snapshot(t0), snapshot(t0, t1)} {
+		span := f.Publish(ctx, topic, s)
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := d.Count("snapshot.published"); n != 2 {
+		t.Error("expected 2 published, got", n)
+	}
+	if n := d.Count("snapshot.unchanged"); n != 1 {
+		t.Error("expected 1 unchanged, got", n)
+	}
+	if readings := f.Readings(snapshot(t0, t1)); len(readings) != 0 {
+		t.Error("expected no new readings", readings)
+	}
+}
+
+func TestPublishBatchOnlyChangedSnapshots(t *testing.T) {
+	t0 := time.Date(2020, 10, 6, 20, 0, 0, 0, time.UTC)
+	f, _ := New("")
+	f.Published(snapshot(t0))
+
+	broker := queue.NewMemory()
+	broker.CreateGroup("test")
+	topic := queue.NewTopic(broker)
+	other := snapshot(t0)
+	other.Station.DataURL = "http://example.com/2"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := f.PublishBatch(ctx, topic, []*gauge.Snapshot{snapshot(t0), other}).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var urls []string
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		urls = append(urls, s.Station.DataURL)
+		cancel()
+		return err
+	})
+	if len(urls) != 1 || urls[0] != "http://example.com/2" {
+		t.Error("expected only the changed snapshot", urls)
+	}
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedupe.json")
+	t0 := time.Date(2020, 10, 6, 20, 0, 0, 0, time.UTC)
+
+	f, span := New(path)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	f.Published(snapshot(t0))
+	if err := f.Save().Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, span := New(path)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if readings := restarted.Readings(snapshot(t0)); len(readings) != 0 {
+		t.Error("expected reading to be remembered", readings)
+	}
+	if readings := restarted.Readings(snapshot(t0.Add(time.Minute))); len(readings) != 1 {
+		t.Error("expected newer reading", readings)
+	}
+}
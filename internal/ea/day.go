@@ -14,6 +14,9 @@ import (
 	"github.com/robtuley/report"
 )
 
+// daySource is the feed of archived telemetry, which is not yet validated
+const daySource = "ea-day"
+
 // Day downloads all the measurements on specified day
 func Day(ctx context.Context, day time.Time) (map[string][]gauge.Reading, report.Span) {
 	url := "http://environment.data.gov.uk/flood-monitoring/archive/readings-" + day.Format("2006-01-02") + ".csv"
@@ -74,7 +77,7 @@ ReadCSV:
 
 // 2016-01-30T00:00:00Z,http://environment.data.gov.uk/flood-monitoring/id/measures/0569TH-level-stage-i-15_min-mASD,3.430
 func csvRecordToReading(r []string) (string, gauge.Reading, error) {
-	var s gauge.Reading
+	s := gauge.Reading{Quality: gauge.QualityProvisional, Source: daySource}
 	var err error
 
 	if len(r) != 3 {
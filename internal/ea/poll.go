@@ -0,0 +1,189 @@
+package ea
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/dedupe"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/report"
+)
+
+// Poller publishes recent EA readings to the queue on a refresh schedule
+//
+// Priority stations are published first each cycle, and the rest only on
+// the slower background refresh period. Snapshots are published in batches
+// of up to BatchSize per message.
+type Poller struct {
+	ProjectID                        string
+	TopicName                        string
+	QueueURL                         string
+	RefreshPeriodInSeconds           int
+	PriorityURLs                     []string // data, alias or human URLs
+	BackgroundRefreshPeriodInSeconds int      // zero is the refresh period
+	MaxPublishPerSecond              int
+	BatchSize                        int    // zero or one publishes each snapshot alone
+	StatePath                        string // blank keeps the dedupe state in memory only
+	ExitAfterXConsecutiveErr         int
+	Cycles                           int // zero polls until shutdown
+}
+
+// Run polls and publishes readings until shutdown or the cycles are complete
+func (cfg Poller) Run(ctx context.Context, d *daemon.Supervisor) error {
+	// discover EA gauging stations
+	stations, span := Discover(ctx)
+	d.Trace(span)
+	if err := span.Err(); err != nil {
+		return err
+	}
+
+	// only publish readings newer than those already published
+	seen, sSpan := dedupe.New(cfg.StatePath)
+	d.Trace(sSpan)
+	if err := sSpan.Err(); err != nil {
+		return err
+	}
+	defer func() { d.Trace(seen.Save()) }()
+
+	priority := gauge.NewPriority(cfg.PriorityURLs)
+	backgroundEvery := gauge.BackgroundEvery(cfg.RefreshPeriodInSeconds, cfg.BackgroundRefreshPeriodInSeconds)
+
+	nConsecutiveErr := 0
+	nCycles := 0
+updateLoop:
+	for {
+		err := func(ctx context.Context) error {
+			// get all recent readings
+			readings, rSpan := Recent(ctx)
+			if err := rSpan.Err(); err != nil {
+				d.Trace(rSpan)
+				return err
+			}
+
+			// open connection to pubsub
+			topic, tSpan := queue.Connect(ctx, cfg.QueueURL, cfg.ProjectID, cfg.TopicName)
+			d.Trace(rSpan.FollowedBy(tSpan))
+			if err := tSpan.Err(); err != nil {
+				return err
+			}
+			defer topic.Stop()
+
+			// ticker to spread readings publish over the full refresh period
+			batches := cfg.batch(dueStations(stations, readings, priority, nCycles, backgroundEvery))
+			every := cfg.durationBetweenPublish(len(batches))
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+
+			// publish readings
+			for _, ids := range batches {
+				snapshots := make([]*gauge.Snapshot, len(ids))
+				for i, id := range ids {
+					snapshots[i] = &gauge.Snapshot{
+						Station:  stations[id],
+						Readings: []gauge.Reading{readings[id]},
+					}
+				}
+
+				var span report.Span
+				if cfg.BatchSize > 1 {
+					span = seen.PublishBatch(ctx, topic, snapshots)
+				} else {
+					span = seen.Publish(ctx, topic, snapshots[0])
+				}
+				d.Trace(span)
+				if err := span.Err(); err != nil {
+					return err
+				}
+
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					// exit early on shutdown
+					return nil
+				}
+			}
+
+			return nil
+		}(ctx)
+
+		if err != nil {
+			nConsecutiveErr++
+			if nConsecutiveErr >= cfg.ExitAfterXConsecutiveErr {
+				// ignore a few isolated errors, but if
+				// many consecutive bubble up to restart
+				return err
+			}
+		} else {
+			nConsecutiveErr = 0
+		}
+
+		// break loop on shutdown signal or once all cycles are done
+		nCycles++
+		if cfg.Cycles > 0 && nCycles >= cfg.Cycles {
+			break updateLoop
+		}
+		select {
+		case <-ctx.Done():
+			break updateLoop
+		default:
+		}
+	}
+
+	return nil
+}
+
+// dueStations provides the IDs of stations with a reading that are due a
+// publish this cycle, with the priority stations first
+func dueStations(stations map[string]gauge.Station, readings map[string]gauge.Reading,
+	priority gauge.Priority, cycle int, backgroundEvery int) []string {
+	var first, rest []string
+	for id := range readings {
+		s, ok := stations[id]
+		if !ok || !priority.IsDue(s, cycle, backgroundEvery) {
+			continue
+		}
+		if priority.Contains(s) {
+			first = append(first, id)
+		} else {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(first)
+	sort.Strings(rest)
+	return append(first, rest...)
+}
+
+// batch splits the station IDs into batches of up to BatchSize in order
+func (cfg Poller) batch(ids []string) [][]string {
+	size := cfg.BatchSize
+	if size < 1 {
+		size = 1
+	}
+	var batches [][]string
+	for len(ids) > size {
+		batches = append(batches, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
+
+func (cfg Poller) durationBetweenPublish(total int) time.Duration {
+	if total == 0 {
+		total = 1
+	}
+	ms := cfg.RefreshPeriodInSeconds * 1000 / total
+	min := 1
+	if cfg.MaxPublishPerSecond > 0 {
+		min = 1000 / cfg.MaxPublishPerSecond
+	}
+	if ms < min {
+		ms = min
+	}
+	return time.Millisecond * time.Duration(ms)
+}
@@ -0,0 +1,80 @@
+package ea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"go.uber.org/goleak"
+)
+
+func TestDryRun(t *testing.T) {
+	// verify no goroutine leaks
+	defer goleak.VerifyNoLeaks(t, goleak.IgnoreTopFunction("go.opencensus.io/stats/view.(*worker).start"))
+	defer http.DefaultTransport.(*http.Transport).CloseIdleConnections()
+
+	// define an accelerated dry run
+	cfg := Poller{
+		RefreshPeriodInSeconds: 3,
+	}
+
+	// init daemon supervisor & context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	d := daemon.New("example")
+
+	// perform the dry run
+	err := cfg.Run(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// validate the log interface
+	expected := []string{
+		"ea.discover",
+		"topic.connected",
+		"ea.recent",
+		"snapshot.published",
+	}
+	for _, evt := range expected {
+		if d.Count(evt) == 0 {
+			t.Fatal(evt + " event expected but not present")
+		}
+	}
+
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	d.CloseWait()
+}
+
+func TestDueStationsPublishesPriorityFirst(t *testing.T) {
+	stations := map[string]gauge.Station{
+		"a": {AliasURL: "rloi://1"},
+		"b": {AliasURL: "rloi://2"},
+		"c": {AliasURL: "rloi://3"},
+	}
+	readings := map[string]gauge.Reading{"a": {}, "b": {}, "c": {}, "unknown": {}}
+	priority := gauge.NewPriority([]string{"rloi://3"})
+
+	if ids := dueStations(stations, readings, priority, 0, 3); fmt.Sprint(ids) != "[c a b]" {
+		t.Error("unexpected first cycle", ids)
+	}
+	if ids := dueStations(stations, readings, priority, 1, 3); fmt.Sprint(ids) != "[c]" {
+		t.Error("unexpected background cycle", ids)
+	}
+}
+
+func TestBatchSplitsStationsInOrder(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+	if batches := (Poller{BatchSize: 2}).batch(ids); fmt.Sprint(batches) != "[[a b] [c d] [e]]" {
+		t.Error("unexpected batches", batches)
+	}
+	if batches := (Poller{}).batch(ids); len(batches) != 5 {
+		t.Error("expected a batch per station", batches)
+	}
+}
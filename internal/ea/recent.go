@@ -20,6 +20,9 @@ type readingJSON struct {
 
 const recentReadingURL = "http://environment.data.gov.uk/flood-monitoring/data/readings?latest"
 
+// recentSource is the feed of latest telemetry, which is not yet validated
+const recentSource = "ea-recent"
+
 // Recent fetches recent EA readings
 func Recent(ctx context.Context) (map[string]gauge.Reading, report.Span) {
 	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
@@ -52,6 +55,8 @@ func Recent(ctx context.Context) (map[string]gauge.Reading, report.Span) {
 		readings[item.Measure] = gauge.Reading{
 			EventTime: item.DateTime,
 			Value:     value,
+			Quality:   gauge.QualityProvisional,
+			Source:    recentSource,
 		}
 	}
 
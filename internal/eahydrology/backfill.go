@@ -0,0 +1,120 @@
+package eahydrology
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+// maxDaysPerRequest keeps each readings request & snapshot a reasonable size
+const maxDaysPerRequest = 31
+
+// Backfill publishes the quality checked readings of each station over a
+// range of days
+type Backfill struct {
+	ProjectID           string
+	TopicName           string
+	QueueURL            string
+	From                time.Time
+	To                  time.Time
+	AliasURLs           []string // zero length is every station
+	MaxPublishPerSecond int
+}
+
+// Run publishes the readings as quality checked snapshots of up to a month
+func (cfg Backfill) Run(ctx context.Context, d *daemon.Supervisor) error {
+	if cfg.To.Before(cfg.From) {
+		return errors.New("backfill to " + cfg.To.Format("2006-01-02") +
+			" is before from " + cfg.From.Format("2006-01-02"))
+	}
+	isDryRun := cfg.ProjectID == "" && cfg.QueueURL == ""
+
+	// discover hydrology measures
+	stations, dSpan := Discover(ctx)
+	if err := dSpan.Err(); err != nil {
+		d.Trace(dSpan)
+		return err
+	}
+	measures := cfg.filter(stations)
+
+	// if dry run shorten the run
+	if isDryRun && len(measures) > 3 {
+		measures = measures[:3]
+	}
+
+	// open connection to pubsub
+	topic, cSpan := queue.Connect(ctx, cfg.QueueURL, cfg.ProjectID, cfg.TopicName)
+	d.Trace(dSpan.FollowedBy(cSpan))
+	if err := cSpan.Err(); err != nil {
+		return err
+	}
+	defer topic.Stop()
+
+	perSecond := cfg.MaxPublishPerSecond
+	if perSecond < 1 {
+		perSecond = 1
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(perSecond))
+	defer ticker.Stop()
+
+	for _, url := range measures {
+		for from := cfg.From; !from.After(cfg.To); from = from.AddDate(0, 0, maxDaysPerRequest) {
+			to := from.AddDate(0, 0, maxDaysPerRequest-1)
+			if to.After(cfg.To) {
+				to = cfg.To
+			}
+
+			readings, span := Readings(ctx, url, from, to)
+			if err := span.Err(); err != nil {
+				d.Trace(span)
+				return err
+			}
+			if len(readings) == 0 {
+				d.Trace(span)
+				continue
+			}
+
+			pSpan := topic.Publish(ctx, &gauge.Snapshot{
+				Station:        stations[url],
+				Readings:       readings,
+				QualityChecked: true,
+			})
+			d.Trace(span.FollowedBy(pSpan))
+			if err := pSpan.Err(); err != nil {
+				return err
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				// exit early on shutdown
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// filter provides the measure URLs to backfill in a consistent order
+func (cfg Backfill) filter(stations map[string]gauge.Station) []string {
+	wanted := make(map[string]bool, len(cfg.AliasURLs))
+	for _, url := range cfg.AliasURLs {
+		wanted[url] = true
+	}
+
+	var urls []string
+	for url, s := range stations {
+		if len(wanted) > 0 && !wanted[s.AliasURL] && !wanted[s.DataURL] {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,73 @@
+package eahydrology
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+func TestBackfillPublishesQualityCheckedSnapshots(t *testing.T) {
+	// replay the API responses from fixtures named after each URL
+	dir := t.TempDir()
+	measureURL := "http://environment.data.gov.uk/hydrology/id/measures/6e3ef0f5-7c2a-4e1b-9f0c-5b8a3c0f1a11-level-i-900-m-qualified"
+	fixtures := map[string]string{
+		stationsURL: "testdata/stations.json",
+		measureURL + "/readings.json?mineq-date=2020-10-05&maxeq-date=2020-10-06&_limit=100000": "testdata/readings.json",
+	}
+	for url, fn := range fixtures {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, daemon.FixtureName(url)), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	daemon.Replay(dir)
+
+	broker := queue.SharedMemory("eahydrology-backfill")
+	broker.CreateGroup("test")
+
+	cfg := Backfill{
+		QueueURL:            "mem://eahydrology-backfill",
+		From:                time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC),
+		To:                  time.Date(2020, 10, 6, 0, 0, 0, 0, time.UTC),
+		AliasURLs:           []string{"rloi://5020"},
+		MaxPublishPerSecond: 100,
+	}
+	d := daemon.New("test")
+	d.Run(context.Background(), func(ctx context.Context, d *daemon.Supervisor) error {
+		defer d.Close()
+		return cfg.Run(ctx, d)
+	})
+	d.CloseAfter(5 * time.Second)
+	d.Wait()
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var snapshots []*gauge.Snapshot
+	queue.NewTopic(broker).Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		snapshots = append(snapshots, s)
+		if broker.Idle() {
+			cancel()
+		}
+		return err
+	})
+
+	if len(snapshots) != 1 {
+		t.Fatal("expected 1 snapshot, got", len(snapshots))
+	}
+	s := snapshots[0]
+	if s.Station.AliasURL != "rloi://5020" || len(s.Readings) != 3 || !s.QualityChecked {
+		t.Error("unexpected snapshot", s)
+	}
+}
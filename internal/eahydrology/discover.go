@@ -0,0 +1,133 @@
+// Package eahydrology backfills quality checked readings from the EA Hydrology API
+package eahydrology
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+const stationsURL = "https://environment.data.gov.uk/hydrology/id/stations.json?status.label=Active&_limit=10000"
+
+// period is the 15 minute interval of the instantaneous measures in seconds
+const period = 900
+
+type stationListJson struct {
+	Stations []stationJson `json:"items"`
+}
+
+type stationJson struct {
+	Url              string `json:"@id"`
+	RLOIid           string
+	RLOIidRawJson    json.RawMessage `json:"RLOIid"`
+	Name             string
+	NameRawJson      json.RawMessage `json:"label"`
+	RiverName        string
+	RiverNameRawJson json.RawMessage `json:"riverName"`
+	Lat              float32
+	Lg               float32
+	LatRawJson       json.RawMessage `json:"lat"`
+	LgRawJson        json.RawMessage `json:"long"`
+	Measures         []measureJson   `json:"measures"`
+}
+
+type measureJson struct {
+	Url    string `json:"@id"`
+	Type   string `json:"parameter"`
+	Period int    `json:"period"`
+}
+
+// Discover finds the 15 minute level and flow measures of active hydrology
+// stations, keyed by measure URL
+func Discover(ctx context.Context) (map[string]gauge.Station, report.Span) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	span := report.StartSpan("eahydrology.discover").Field("url", stationsURL)
+
+	resp, err := daemon.JSON(ctx, stationsURL)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+
+	stations, err := parseStations(resp.Body)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("measures_count", len(stations))
+	return stations, span.End()
+}
+
+func parseStations(r io.Reader) (map[string]gauge.Station, error) {
+	list := stationListJson{}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	stations := make(map[string]gauge.Station)
+	for _, s := range list.Stations {
+		// as with the flood monitoring API, fields can be an array
+		// so are parsed defensively and allowed to be missing
+		s.Lat, _ = daemon.ParseFloat(s.LatRawJson)
+		s.Lg, _ = daemon.ParseFloat(s.LgRawJson)
+		s.RLOIid, _ = daemon.ParseString(s.RLOIidRawJson)
+		s.Name, _ = daemon.ParseString(s.NameRawJson)
+		s.RiverName, _ = daemon.ParseString(s.RiverNameRawJson)
+
+		for _, m := range s.Measures {
+			// the parameter & period are implied by the measure URL
+			// e.g. .../measures/{guid}-level-i-900-m-qualified
+			if m.Type == "" {
+				switch {
+				case strings.Contains(m.Url, "-level-"):
+					m.Type = "level"
+				case strings.Contains(m.Url, "-flow-"):
+					m.Type = "flow"
+				}
+			}
+			if m.Period == 0 && strings.Contains(m.Url, "-i-900-") {
+				m.Period = period
+			}
+			if m.Period != period {
+				continue
+			}
+
+			var unit string
+			switch m.Type {
+			case "level":
+				unit = "m"
+			case "flow":
+				unit = "m3/s"
+			default:
+				continue
+			}
+
+			// only the level measure shares the RLOI alias with the flood
+			// monitoring API, so flow is never merged into level readings
+			aliasURL := m.Url
+			if s.RLOIid != "" && m.Type == "level" {
+				aliasURL = "rloi://" + s.RLOIid
+			}
+
+			stations[m.Url] = gauge.Station{
+				DataURL:   m.Url,
+				AliasURL:  aliasURL,
+				HumanURL:  s.Url,
+				Name:      s.Name,
+				RiverName: s.RiverName,
+				Lat:       s.Lat,
+				Lg:        s.Lg,
+				Type:      m.Type,
+				Unit:      unit,
+			}
+		}
+	}
+	return stations, nil
+}
@@ -0,0 +1,36 @@
+package eahydrology
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseStations(t *testing.T) {
+	f, err := os.Open("testdata/stations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stations, err := parseStations(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 2 {
+		t.Fatal("expected 15 minute level & flow measures only, got", stations)
+	}
+
+	level := stations["http://environment.data.gov.uk/hydrology/id/measures/6e3ef0f5-7c2a-4e1b-9f0c-5b8a3c0f1a11-level-i-900-m-qualified"]
+	if level.AliasURL != "rloi://5020" || level.Type != "level" || level.Unit != "m" {
+		t.Error("unexpected level station", level)
+	}
+	if level.Name != "Sedbergh" || level.RiverName != "Rawthey" || level.Lat != 54.3234 {
+		t.Error("unexpected level station details", level)
+	}
+
+	flowURL := "http://environment.data.gov.uk/hydrology/id/measures/6e3ef0f5-7c2a-4e1b-9f0c-5b8a3c0f1a11-flow-i-900-m3s-qualified"
+	flow := stations[flowURL]
+	if flow.AliasURL != flowURL || flow.Type != "flow" || flow.Unit != "m3/s" {
+		t.Error("unexpected flow station", flow)
+	}
+}
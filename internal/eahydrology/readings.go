@@ -0,0 +1,103 @@
+package eahydrology
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+// dateTime is the hydrology API timestamp format, always in GMT
+const dateTime = "2006-01-02T15:04:05"
+
+// source is the feed of quality checked hydrology readings
+const source = "ea-hydrology"
+
+type readingListJson struct {
+	Readings []readingJson `json:"items"`
+}
+
+type readingJson struct {
+	DateTime string   `json:"dateTime"`
+	Value    *float32 `json:"value"`
+	Quality  string   `json:"quality"`
+}
+
+// Readings downloads the quality checked readings of a measure from the
+// start of day from up to and including day to
+//
+// Only readings of Good or Estimated quality are returned, so Suspect,
+// Unchecked and Missing readings are dropped.
+func Readings(ctx context.Context, measureURL string, from time.Time, to time.Time) ([]gauge.Reading, report.Span) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	url := readingsURL(measureURL, from, to)
+	span := report.StartSpan("eahydrology.readings").Field("url", url)
+
+	resp, err := daemon.JSON(ctx, url)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+
+	readings, nRejected, err := parseReadings(resp.Body)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("readings_count", len(readings))
+	span = span.Field("rejected_count", nRejected)
+	return readings, span.End()
+}
+
+// readingsURL queries the days from and to inclusive, as max-date would
+// exclude day to
+func readingsURL(measureURL string, from time.Time, to time.Time) string {
+	return measureURL + "/readings.json?mineq-date=" + from.Format("2006-01-02") +
+		"&maxeq-date=" + to.Format("2006-01-02") + "&_limit=100000"
+}
+
+func parseReadings(r io.Reader) ([]gauge.Reading, int, error) {
+	list := readingListJson{}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, 0, err
+	}
+
+	var readings []gauge.Reading
+	nRejected := 0
+	for _, r := range list.Readings {
+		var quality string
+		switch r.Quality {
+		case "Good":
+			quality = gauge.QualityValidated
+		case "Estimated":
+			quality = gauge.QualityEstimated
+		default:
+			nRejected++
+			continue
+		}
+		if r.Value == nil {
+			nRejected++
+			continue
+		}
+		t, err := time.Parse(dateTime, r.DateTime)
+		if err != nil {
+			// some responses include a zone designator
+			t, err = time.Parse(time.RFC3339, r.DateTime)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		readings = append(readings, gauge.Reading{
+			EventTime: t,
+			Value:     *r.Value,
+			Quality:   quality,
+			Source:    source,
+		})
+	}
+	return readings, nRejected, nil
+}
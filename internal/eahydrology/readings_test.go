@@ -0,0 +1,50 @@
+package eahydrology
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestParseReadings(t *testing.T) {
+	f, err := os.Open("testdata/readings.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	readings, nRejected, err := parseReadings(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 3 || nRejected != 3 {
+		t.Fatal("expected Good & Estimated readings only", readings, nRejected)
+	}
+	expected := time.Date(2020, 10, 5, 0, 15, 0, 0, time.UTC)
+	if !readings[1].EventTime.Equal(expected) || readings[1].Value != 0.415 {
+		t.Error("unexpected reading", readings[1])
+	}
+	if readings[0].Quality != gauge.QualityValidated || readings[1].Quality != gauge.QualityEstimated {
+		t.Error("unexpected quality", readings)
+	}
+	if readings[0].Source != "ea-hydrology" {
+		t.Error("unexpected source", readings[0])
+	}
+}
+
+func TestReadingsURLIncludesLastDayOfChunk(t *testing.T) {
+	// a 31 day chunk starting on the 1st of January ends on the 31st, and
+	// the next chunk starts on the 1st of February
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, maxDaysPerRequest-1)
+	url := readingsURL("http://example.com/measure", from, to)
+	expected := "http://example.com/measure/readings.json?mineq-date=2020-01-01&maxeq-date=2020-01-31&_limit=100000"
+	if url != expected {
+		t.Error("unexpected URL", url)
+	}
+	if next := from.AddDate(0, 0, maxDaysPerRequest); next.Format("2006-01-02") != "2020-02-01" {
+		t.Error("next chunk does not follow on", next)
+	}
+}
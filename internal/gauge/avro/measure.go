@@ -13,6 +13,8 @@ import (
 type Measure struct {
 	Event_time int64
 	Value      float32
+	Quality    QualityValues
+	Source     string
 }
 
 func DeserializeMeasure(r io.Reader) (*Measure, error) {
@@ -26,7 +28,7 @@ func NewMeasure() *Measure {
 }
 
 func (r *Measure) Schema() string {
-	return "{\"doc:\":\"Gauge measurement information\",\"fields\":[{\"doc\":\"Unix epoch time in seconds for measurement event time\",\"name\":\"event_time\",\"type\":\"long\"},{\"doc\":\"Measurement value\",\"name\":\"value\",\"type\":\"float\"}],\"name\":\"measure\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"}"
+	return "{\"doc:\":\"Gauge measurement information\",\"fields\":[{\"doc\":\"Unix epoch time in seconds for measurement event time\",\"name\":\"event_time\",\"type\":\"long\"},{\"doc\":\"Measurement value\",\"name\":\"value\",\"type\":\"float\"},{\"default\":\"unknown\",\"doc\":\"Quality of the measurement value\",\"name\":\"quality\",\"type\":{\"name\":\"qualityValues\",\"symbols\":[\"unknown\",\"suspect\",\"estimated\",\"provisional\",\"validated\"],\"type\":\"enum\"}},{\"default\":\"\",\"doc\":\"Source feed of the measurement\",\"name\":\"source\",\"type\":\"string\"}],\"name\":\"measure\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"}"
 }
 
 func (r *Measure) Serialize(w io.Writer) error {
@@ -125,6 +125,47 @@ func readArrayMeasure(r io.Reader) ([]*Measure, error) {
 	return arr, nil
 }
 
+func readArraySnapshot(r io.Reader) ([]*Snapshot, error) {
+	var err error
+	var blkSize int64
+	var arr = make([]*Snapshot, 0)
+	for {
+		blkSize, err = readLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if blkSize == 0 {
+			break
+		}
+		if blkSize < 0 {
+			blkSize = -blkSize
+			_, err = readLong(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for i := int64(0); i < blkSize; i++ {
+			elem, err := readSnapshot(r)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+	}
+	return arr, nil
+}
+
+func readBatch(r io.Reader) (*Batch, error) {
+	var str = &Batch{}
+	var err error
+	str.Snapshots, err = readArraySnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return str, nil
+}
+
 func readFloat(r io.Reader) (float32, error) {
 	buf := make([]byte, 4)
 	_, err := io.ReadFull(r, buf)
@@ -182,10 +223,23 @@ func readMeasure(r io.Reader) (*Measure, error) {
 	if err != nil {
 		return nil, err
 	}
+	str.Quality, err = readQualityValues(r)
+	if err != nil {
+		return nil, err
+	}
+	str.Source, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
 
 	return str, nil
 }
 
+func readQualityValues(r io.Reader) (QualityValues, error) {
+	val, err := readInt(r)
+	return QualityValues(val), err
+}
+
 func readSnapshot(r io.Reader) (*Snapshot, error) {
 	var str = &Snapshot{}
 	var err error
@@ -241,6 +295,10 @@ func readSnapshot(r io.Reader) (*Snapshot, error) {
 	if err != nil {
 		return nil, err
 	}
+	str.Name_cy, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
 
 	return str, nil
 }
@@ -270,29 +328,35 @@ func readTypeValues(r io.Reader) (TypeValues, error) {
 	return TypeValues(val), err
 }
 
-func readUnionMeasureSnapshot(r io.Reader) (UnionMeasureSnapshot, error) {
+func readUnionMeasureSnapshotBatch(r io.Reader) (UnionMeasureSnapshotBatch, error) {
 	field, err := readLong(r)
-	var unionStr UnionMeasureSnapshot
+	var unionStr UnionMeasureSnapshotBatch
 	if err != nil {
 		return unionStr, err
 	}
-	unionStr.UnionType = UnionMeasureSnapshotTypeEnum(field)
+	unionStr.UnionType = UnionMeasureSnapshotBatchTypeEnum(field)
 	switch unionStr.UnionType {
-	case UnionMeasureSnapshotTypeEnumMeasure:
+	case UnionMeasureSnapshotBatchTypeEnumMeasure:
 		val, err := readMeasure(r)
 		if err != nil {
 			return unionStr, err
 		}
 		unionStr.Measure = val
-	case UnionMeasureSnapshotTypeEnumSnapshot:
+	case UnionMeasureSnapshotBatchTypeEnumSnapshot:
 		val, err := readSnapshot(r)
 		if err != nil {
 			return unionStr, err
 		}
 		unionStr.Snapshot = val
+	case UnionMeasureSnapshotBatchTypeEnumBatch:
+		val, err := readBatch(r)
+		if err != nil {
+			return unionStr, err
+		}
+		unionStr.Batch = val
 
 	default:
-		return unionStr, fmt.Errorf("Invalid value for UnionMeasureSnapshot")
+		return unionStr, fmt.Errorf("Invalid value for UnionMeasureSnapshotBatch")
 	}
 	return unionStr, nil
 }
@@ -311,6 +375,30 @@ func writeArrayMeasure(r []*Measure, w io.Writer) error {
 	return writeLong(0, w)
 }
 
+func writeArraySnapshot(r []*Snapshot, w io.Writer) error {
+	err := writeLong(int64(len(r)), w)
+	if err != nil || len(r) == 0 {
+		return err
+	}
+	for _, e := range r {
+		err = writeSnapshot(e, w)
+		if err != nil {
+			return err
+		}
+	}
+	return writeLong(0, w)
+}
+
+func writeBatch(r *Batch, w io.Writer) error {
+	var err error
+	err = writeArraySnapshot(r.Snapshots, w)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func writeFloat(r float32, w io.Writer) error {
 	bits := uint64(math.Float32bits(r))
 	const byteCount = 4
@@ -341,9 +429,21 @@ func writeMeasure(r *Measure, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	err = writeQualityValues(r.Quality, w)
+	if err != nil {
+		return err
+	}
+	err = writeString(r.Source, w)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
+func writeQualityValues(r QualityValues, w io.Writer) error {
+	return writeInt(int32(r), w)
+}
+
 func writeSnapshot(r *Snapshot, w io.Writer) error {
 	var err error
 	err = writeString(r.Data_url, w)
@@ -398,6 +498,10 @@ func writeSnapshot(r *Snapshot, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	err = writeString(r.Name_cy, w)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -419,17 +523,19 @@ func writeTypeValues(r TypeValues, w io.Writer) error {
 	return writeInt(int32(r), w)
 }
 
-func writeUnionMeasureSnapshot(r UnionMeasureSnapshot, w io.Writer) error {
+func writeUnionMeasureSnapshotBatch(r UnionMeasureSnapshotBatch, w io.Writer) error {
 	err := writeLong(int64(r.UnionType), w)
 	if err != nil {
 		return err
 	}
 	switch r.UnionType {
-	case UnionMeasureSnapshotTypeEnumMeasure:
+	case UnionMeasureSnapshotBatchTypeEnumMeasure:
 		return writeMeasure(r.Measure, w)
-	case UnionMeasureSnapshotTypeEnumSnapshot:
+	case UnionMeasureSnapshotBatchTypeEnumSnapshot:
 		return writeSnapshot(r.Snapshot, w)
+	case UnionMeasureSnapshotBatchTypeEnumBatch:
+		return writeBatch(r.Batch, w)
 
 	}
-	return fmt.Errorf("Invalid value for UnionMeasureSnapshot")
+	return fmt.Errorf("Invalid value for UnionMeasureSnapshotBatch")
 }
@@ -24,6 +24,7 @@ type Snapshot struct {
 	Correlation_id string
 	Causation_id   string
 	Processed_time int64
+	Name_cy        string
 }
 
 func DeserializeSnapshot(r io.Reader) (*Snapshot, error) {
@@ -39,7 +40,7 @@ func NewSnapshot() *Snapshot {
 }
 
 func (r *Snapshot) Schema() string {
-	return "{\"doc:\":\"Gauge measurement record information and reading snapshot\",\"fields\":[{\"doc\":\"Data URL for the gauge measurement\",\"name\":\"data_url\",\"type\":\"string\"},{\"doc\":\"Alias URL as a reference to this station\",\"name\":\"alias_url\",\"type\":\"string\"},{\"doc\":\"Human linkable URL for the station\",\"name\":\"human_url\",\"type\":\"string\"},{\"doc\":\"Human-readable name of the measurement\",\"name\":\"name\",\"type\":\"string\"},{\"doc\":\"Name of the river measured\",\"name\":\"river_name\",\"type\":\"string\"},{\"doc\":\"Location latitude\",\"name\":\"lat\",\"type\":\"float\"},{\"doc\":\"Location longitude\",\"name\":\"lg\",\"type\":\"float\"},{\"doc\":\"Measurement unit\",\"name\":\"unit\",\"type\":\"string\"},{\"doc\":\"Measurement type\",\"name\":\"type\",\"type\":{\"name\":\"typeValues\",\"symbols\":[\"level\",\"flow\",\"temperature\",\"rainfall\"],\"type\":\"enum\"}},{\"name\":\"readings\",\"type\":{\"items\":{\"doc:\":\"Gauge measurement information\",\"fields\":[{\"doc\":\"Unix epoch time in seconds for measurement event time\",\"name\":\"event_time\",\"type\":\"long\"},{\"doc\":\"Measurement value\",\"name\":\"value\",\"type\":\"float\"}],\"name\":\"measure\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"},\"type\":\"array\"}},{\"doc\":\"Correlation ID to generate this snapshot, can be used as a version identifier\",\"name\":\"correlation_id\",\"type\":\"string\"},{\"doc\":\"Causation ID to generate this snapshot\",\"name\":\"causation_id\",\"type\":\"string\"},{\"doc\":\"Unix epoch time in seconds for timestamp at which measurement was processed\",\"name\":\"processed_time\",\"type\":\"long\"}],\"name\":\"snapshot\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"}"
+	return "{\"doc:\":\"Gauge measurement record information and reading snapshot\",\"fields\":[{\"doc\":\"Data URL for the gauge measurement\",\"name\":\"data_url\",\"type\":\"string\"},{\"doc\":\"Alias URL as a reference to this station\",\"name\":\"alias_url\",\"type\":\"string\"},{\"doc\":\"Human linkable URL for the station\",\"name\":\"human_url\",\"type\":\"string\"},{\"doc\":\"Human-readable name of the measurement\",\"name\":\"name\",\"type\":\"string\"},{\"doc\":\"Name of the river measured\",\"name\":\"river_name\",\"type\":\"string\"},{\"doc\":\"Location latitude\",\"name\":\"lat\",\"type\":\"float\"},{\"doc\":\"Location longitude\",\"name\":\"lg\",\"type\":\"float\"},{\"doc\":\"Measurement unit\",\"name\":\"unit\",\"type\":\"string\"},{\"doc\":\"Measurement type\",\"name\":\"type\",\"type\":{\"name\":\"typeValues\",\"symbols\":[\"level\",\"flow\",\"temperature\",\"rainfall\",\"tidal\"],\"type\":\"enum\"}},{\"name\":\"readings\",\"type\":{\"items\":{\"doc:\":\"Gauge measurement information\",\"fields\":[{\"doc\":\"Unix epoch time in seconds for measurement event time\",\"name\":\"event_time\",\"type\":\"long\"},{\"doc\":\"Measurement value\",\"name\":\"value\",\"type\":\"float\"},{\"default\":\"unknown\",\"doc\":\"Quality of the measurement value\",\"name\":\"quality\",\"type\":{\"name\":\"qualityValues\",\"symbols\":[\"unknown\",\"suspect\",\"estimated\",\"provisional\",\"validated\"],\"type\":\"enum\"}},{\"default\":\"\",\"doc\":\"Source feed of the measurement\",\"name\":\"source\",\"type\":\"string\"}],\"name\":\"measure\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"},\"type\":\"array\"}},{\"doc\":\"Correlation ID to generate this snapshot, can be used as a version identifier\",\"name\":\"correlation_id\",\"type\":\"string\"},{\"doc\":\"Causation ID to generate this snapshot\",\"name\":\"causation_id\",\"type\":\"string\"},{\"doc\":\"Unix epoch time in seconds for timestamp at which measurement was processed\",\"name\":\"processed_time\",\"type\":\"long\"},{\"default\":\"\",\"doc\":\"Welsh name of the measurement if available\",\"name\":\"name_cy\",\"type\":\"string\"}],\"name\":\"snapshot\",\"namespace\":\"com.rainchasers.gauge\",\"type\":\"record\"}"
 }
 
 func (r *Snapshot) Serialize(w io.Writer) error {
@@ -13,6 +13,7 @@ const (
 	Flow        TypeValues = 1
 	Temperature TypeValues = 2
 	Rainfall    TypeValues = 3
+	Tidal       TypeValues = 4
 )
 
 func (e TypeValues) String() string {
@@ -25,6 +26,8 @@ func (e TypeValues) String() string {
 		return "temperature"
 	case Rainfall:
 		return "rainfall"
+	case Tidal:
+		return "tidal"
 
 	}
 	return "Unknown"
@@ -10,6 +10,7 @@ type Station struct {
 	AliasURL  string  `firestore:"alias_url" json:"alias_url"`
 	HumanURL  string  `firestore:"human_url" json:"human_url"`
 	Name      string  `firestore:"name" json:"name"`
+	NameCY    string  `firestore:"name_cy,omitempty" json:"name_cy,omitempty"` // Welsh
 	RiverName string  `firestore:"river" json:"river"`
 	Lat       float32 `firestore:"lat" json:"lat"`
 	Lg        float32 `firestore:"lng" json:"lng"`
@@ -21,6 +22,34 @@ type Station struct {
 type Reading struct {
 	EventTime time.Time `firestore:"time" json:"time"`
 	Value     float32   `firestore:"value" json:"value"`
+	Quality   string    `firestore:"quality,omitempty" json:"quality,omitempty"`
+	Source    string    `firestore:"source,omitempty" json:"source,omitempty"` // feed, e.g. ea-recent
+}
+
+// Reading quality flags, a blank quality is unknown
+const (
+	QualitySuspect     = "suspect"
+	QualityEstimated   = "estimated"
+	QualityProvisional = "provisional"
+	QualityValidated   = "validated"
+)
+
+// qualityRank orders quality from least to most trusted
+//
+// Estimated and validated readings have both been checked by the source
+// (an estimate fills a gap or corrects a faulty reading), so either
+// outranks the live provisional telemetry they are backfilled over.
+var qualityRank = map[string]int{
+	QualitySuspect:     1,
+	"":                 2,
+	QualityProvisional: 3,
+	QualityEstimated:   4,
+	QualityValidated:   5,
+}
+
+// Outranks is true if the reading is of higher quality than another
+func (r Reading) Outranks(other Reading) bool {
+	return qualityRank[r.Quality] > qualityRank[other.Quality]
 }
 
 // Snapshot is a set of measurements for a particular gauge station
@@ -30,4 +59,8 @@ type Snapshot struct {
 	CorrelationID string    `json:"correlation_id"`
 	CausationID   string    `json:"causation_id"`
 	ProcessedTime time.Time `json:"processed_time"`
+
+	// QualityChecked readings have been validated by the source rather
+	// than being raw telemetry (carried as a message attribute)
+	QualityChecked bool `json:"quality_checked"`
 }
@@ -0,0 +1,42 @@
+package gauge
+
+// Priority is the set of station URLs to refresh first and most often,
+// e.g. the gauges calibrated for a section
+//
+// A station matches on any of its data, alias or human URLs in the same
+// way a calibration does.
+type Priority map[string]bool
+
+// NewPriority creates the priority set from a list of URLs
+func NewPriority(urls []string) Priority {
+	p := make(Priority, len(urls))
+	for _, url := range urls {
+		p[url] = true
+	}
+	return p
+}
+
+// Contains is true if the station is a priority
+func (p Priority) Contains(s Station) bool {
+	return p[s.DataURL] || p[s.AliasURL] || p[s.HumanURL]
+}
+
+// IsDue is true if the station should be refreshed on the cycle, with
+// priority stations due every cycle and the others every nth cycle
+//
+// With no priority set every station is due every cycle.
+func (p Priority) IsDue(s Station, cycle int, n int) bool {
+	if len(p) == 0 || n <= 1 || p.Contains(s) {
+		return true
+	}
+	return cycle%n == 0
+}
+
+// BackgroundEvery is the number of refresh cycles between each refresh
+// of the stations that are not a priority, at least 1
+func BackgroundEvery(refreshPeriodInSeconds int, backgroundPeriodInSeconds int) int {
+	if refreshPeriodInSeconds <= 0 || backgroundPeriodInSeconds <= refreshPeriodInSeconds {
+		return 1
+	}
+	return (backgroundPeriodInSeconds + refreshPeriodInSeconds - 1) / refreshPeriodInSeconds
+}
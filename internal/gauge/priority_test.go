@@ -0,0 +1,51 @@
+package gauge
+
+import "testing"
+
+func TestPriorityMatchesAnyURL(t *testing.T) {
+	p := NewPriority([]string{"rloi://5020", "http://example.com/human"})
+	if !p.Contains(Station{AliasURL: "rloi://5020"}) {
+		t.Error("expected alias URL match")
+	}
+	if !p.Contains(Station{HumanURL: "http://example.com/human"}) {
+		t.Error("expected human URL match")
+	}
+	if p.Contains(Station{DataURL: "rloi://5021"}) {
+		t.Error("unexpected match")
+	}
+}
+
+func TestPriorityIsDue(t *testing.T) {
+	p := NewPriority([]string{"rloi://5020"})
+	calibrated := Station{AliasURL: "rloi://5020"}
+	other := Station{AliasURL: "rloi://5021"}
+
+	for cycle := 0; cycle < 6; cycle++ {
+		if !p.IsDue(calibrated, cycle, 3) {
+			t.Error("calibrated station not due on cycle", cycle)
+		}
+		if isDue := p.IsDue(other, cycle, 3); isDue != (cycle%3 == 0) {
+			t.Error("unexpected background due on cycle", cycle, isDue)
+		}
+	}
+	if !NewPriority(nil).IsDue(other, 1, 3) {
+		t.Error("expected every station due with no priority set")
+	}
+}
+
+func TestBackgroundEvery(t *testing.T) {
+	expected := []struct {
+		refresh, background, every int
+	}{
+		{300, 0, 1},
+		{300, 300, 1},
+		{300, 900, 3},
+		{300, 1000, 4},
+		{0, 900, 1},
+	}
+	for _, e := range expected {
+		if n := BackgroundEvery(e.refresh, e.background); n != e.every {
+			t.Error(e, "got", n)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package gauge
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/robtuley/rainchasers/internal/gauge/avro"
+)
+
+// SchemaVersion is the version of gauge.avsc the generated code is built from
+//
+// Increment it and add the new gauge.avsc to the schemas directory whenever
+// the schema changes.
+const SchemaVersion = 4
+
+// Registry is a stand-in schema registry of each gauge.avsc version
+//
+// A message records its writer schema version and fingerprint, and is
+// decoded by resolving that writer schema against the current one.
+type Registry struct {
+	byVersion     map[int]*Schema
+	byFingerprint map[uint64]*Schema
+}
+
+// CurrentSchema is the schema used to encode snapshots
+var CurrentSchema = func() *Schema {
+	// gauge.avsc is a union of the generated record schemas
+	union := "[" + avro.NewMeasure().Schema() + "," + avro.NewSnapshot().Schema() +
+		"," + avro.NewBatch().Schema() + "]"
+	s, err := ParseSchema(SchemaVersion, []byte(union))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+//go:embed schemas/*.avsc
+var releasedSchemas embed.FS
+
+// NewRegistry creates a registry of every released schema in the schemas
+// directory, which is compiled in
+func NewRegistry() *Registry {
+	r := &Registry{
+		byVersion:     make(map[int]*Schema),
+		byFingerprint: make(map[uint64]*Schema),
+	}
+	r.Add(CurrentSchema)
+	if err := r.addFS(releasedSchemas, "schemas"); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LoadRegistry adds every <version>.avsc file in dir to a new registry,
+// e.g. a newer schema not yet compiled in
+func LoadRegistry(dir string) (*Registry, error) {
+	r := NewRegistry()
+	if err := r.addFS(os.DirFS(dir), "."); err != nil {
+		return nil, errors.New(dir + ": " + err.Error())
+	}
+	return r, nil
+}
+
+// addFS adds every <version>.avsc file in dir of fsys
+func (r *Registry) addFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.avsc"))
+	if err != nil {
+		return err
+	}
+	for _, fn := range files {
+		version, err := strconv.Atoi(strings.TrimSuffix(path.Base(fn), ".avsc"))
+		if err != nil {
+			return errors.New("schema file " + fn + " is not named by version")
+		}
+		b, err := fs.ReadFile(fsys, fn)
+		if err != nil {
+			return err
+		}
+		s, err := ParseSchema(version, b)
+		if err != nil {
+			return errors.New(fn + ": " + err.Error())
+		}
+		if version == SchemaVersion && s.Fingerprint != CurrentSchema.Fingerprint {
+			return errors.New(fn + " does not match the current schema")
+		}
+		r.Add(s)
+	}
+	return nil
+}
+
+// Add registers a schema version
+func (r *Registry) Add(s *Schema) {
+	r.byVersion[s.Version] = s
+	r.byFingerprint[s.Fingerprint] = s
+}
+
+// Lookup finds a writer schema by fingerprint, falling back to the version
+func (r *Registry) Lookup(version int, fingerprint uint64) (*Schema, error) {
+	if s, ok := r.byFingerprint[fingerprint]; ok {
+		return s, nil
+	}
+	if s, ok := r.byVersion[version]; ok {
+		return s, nil
+	}
+	return nil, errors.New("unknown schema version " + strconv.Itoa(version) +
+		" fingerprint " + strconv.FormatUint(fingerprint, 16))
+}
@@ -0,0 +1,222 @@
+package gauge
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	// test vectors from the Avro specification reference implementation
+	for schema, expect := range map[string]uint64{
+		`"null"`:          7195948357588979594,
+		`{"type": "int"}`: 8247732601305521295,
+	} {
+		s, err := ParseSchema(1, []byte(schema))
+		if err != nil {
+			t.Fatal(schema, err)
+		}
+		if s.Fingerprint != expect {
+			t.Error("Fingerprint mis-match", schema, s.Canonical, s.Fingerprint)
+		}
+	}
+}
+
+func TestParsingCanonicalForm(t *testing.T) {
+	for schema, expect := range map[string]string{
+		`["null", "string"]`: `["null","string"]`,
+		`{"type": "fixed", "size": 4, "name": "f", "doc": "x"}`:                                                      `{"name":"f","type":"fixed","size":4}`,
+		`{"type": "record", "name": "r", "namespace": "n", "fields": [{"name": "a", "type": "long", "default": 0}]}`: `{"name":"n.r","type":"record","fields":[{"name":"a","type":"long"}]}`,
+	} {
+		s, err := ParseSchema(1, []byte(schema))
+		if err != nil {
+			t.Fatal(schema, err)
+		}
+		if s.Canonical != expect {
+			t.Error("Canonical form mis-match", s.Canonical)
+		}
+	}
+}
+
+func TestLoadRegistry(t *testing.T) {
+	r, err := LoadRegistry("schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := r.Lookup(SchemaVersion, CurrentSchema.Fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Canonical != CurrentSchema.Canonical {
+		t.Error("schemas/" + strconv.Itoa(SchemaVersion) + ".avsc does not match the generated code")
+	}
+
+	v1, err := r.Lookup(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1.Fingerprint == CurrentSchema.Fingerprint {
+		t.Error("Version 1 has the current fingerprint")
+	}
+
+	if _, err := r.Lookup(99, 0); err == nil {
+		t.Error("Expected unknown schema error")
+	}
+}
+
+func testSnapshot() Snapshot {
+	return Snapshot{
+		Station: Station{
+			DataURL:   "http://example.com/data",
+			AliasURL:  "rloi://1234",
+			Name:      "Bourton Dickler",
+			RiverName: "Dikler",
+			Lat:       51.874767,
+			Lg:        -1.740083,
+			Type:      "flow",
+			Unit:      "m3/s",
+		},
+		Readings: []Reading{
+			{EventTime: time.Unix(1600000000, 0), Value: 1.23},
+			{EventTime: time.Unix(1600000900, 0), Value: 4.56},
+		},
+		CorrelationID: "ABCDE",
+		CausationID:   "FGHIJ",
+		ProcessedTime: time.Unix(1600001000, 0),
+	}
+}
+
+func assertSnapshotsMatch(t *testing.T, before Snapshot, after Snapshot) {
+	t.Helper()
+	if before.Station != after.Station {
+		t.Error("Station mis-match", after.Station)
+	}
+	if len(after.Readings) != len(before.Readings) {
+		t.Fatal("Readings mis-match", after.Readings)
+	}
+	for i := range before.Readings {
+		if !after.Readings[i].EventTime.Equal(before.Readings[i].EventTime) ||
+			after.Readings[i].Value != before.Readings[i].Value ||
+			after.Readings[i].Quality != before.Readings[i].Quality ||
+			after.Readings[i].Source != before.Readings[i].Source {
+			t.Error("Reading mis-match", after.Readings[i])
+		}
+	}
+	if after.CorrelationID != before.CorrelationID || after.CausationID != before.CausationID {
+		t.Error("Trace ID mis-match", after)
+	}
+	if !after.ProcessedTime.Equal(before.ProcessedTime) {
+		t.Error("Processed time mis-match", after.ProcessedTime)
+	}
+}
+
+func TestDecodeWithOlderSchema(t *testing.T) {
+	r, err := LoadRegistry("schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// recorded encodings of testSnapshot, version 2 with a Welsh name
+	for version, fn := range map[int]string{
+		1: "testdata/snapshot-v1.avro",
+		2: "testdata/snapshot-v2.avro",
+	} {
+		writer, err := r.Lookup(version, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := testSnapshot()
+		if version > 1 {
+			before.Station.NameCY = "Bourton Dickler (CY)"
+		}
+		after := Snapshot{}
+		if err := after.DecodeWith(bytes.NewReader(b), writer); err != nil {
+			t.Fatal(fn, err)
+		}
+		assertSnapshotsMatch(t, before, after)
+	}
+}
+
+func TestDecodeWithNewerSchema(t *testing.T) {
+	// a future version that adds a nullable source field
+	newer, err := ParseSchema(5, []byte(`{
+		"type": "record",
+		"name": "snapshot",
+		"namespace": "com.rainchasers.gauge",
+		"fields": [
+			{"name": "data_url", "type": "string"},
+			{"name": "alias_url", "type": "string"},
+			{"name": "human_url", "type": "string"},
+			{"name": "name", "type": "string"},
+			{"name": "river_name", "type": "string"},
+			{"name": "lat", "type": "float"},
+			{"name": "lg", "type": "float"},
+			{"name": "unit", "type": "string"},
+			{"name": "type", "type": {"type": "enum", "name": "typeValues",
+				"symbols": ["level", "flow", "temperature", "rainfall", "tidal"]}},
+			{"name": "readings", "type": {"type": "array", "items": {
+				"type": "record", "name": "measure", "fields": [
+					{"name": "event_time", "type": "long"},
+					{"name": "value", "type": "float"},
+					{"name": "quality", "type": {"type": "enum", "name": "qualityValues",
+						"symbols": ["unknown", "suspect", "estimated", "provisional", "validated"]}},
+					{"name": "source", "type": "string"}
+				]}}},
+			{"name": "correlation_id", "type": "string"},
+			{"name": "causation_id", "type": "string"},
+			{"name": "processed_time", "type": "long"},
+			{"name": "name_cy", "type": "string", "default": ""},
+			{"name": "source", "type": ["null", "string"], "default": null}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newer.Fingerprint == CurrentSchema.Fingerprint {
+		t.Fatal("Newer schema has the current fingerprint")
+	}
+
+	before := testSnapshot()
+	before.Station.NameCY = "Bourton Dickler (CY)"
+	before.Readings[0].Quality = QualityValidated
+	before.Readings[0].Source = "ea-hydrology"
+	var bb bytes.Buffer
+	if err := before.Encode(&bb); err != nil {
+		t.Fatal(err)
+	}
+	// union branch 1 (zigzag 2) then the 3 byte string "api" (zigzag 6)
+	bb.Write([]byte{2, 6, 'a', 'p', 'i'})
+
+	after := Snapshot{}
+	if err := after.DecodeWith(&bb, newer); err != nil {
+		t.Fatal(err)
+	}
+	assertSnapshotsMatch(t, before, after)
+	if bb.Len() != 0 {
+		t.Error("Unread bytes remain", bb.Len())
+	}
+}
+
+func TestDecodeBatchWithOlderSchema(t *testing.T) {
+	r, err := LoadRegistry("schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := r.Lookup(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// version 1 has no batch record
+	if _, err := DecodeBatchWith(bytes.NewReader(nil), v1); err == nil {
+		t.Error("Expected missing batch record error")
+	}
+}
@@ -0,0 +1,251 @@
+package gauge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// DecodeWith reads a Snapshot written with the writer schema, which may be
+// an older or newer version than the current schema
+//
+// Fields are matched by name, so writer fields unknown to this version are
+// skipped and missing fields take their zero value default.
+func (s *Snapshot) DecodeWith(r io.Reader, writer *Schema) error {
+	if writer.Fingerprint == CurrentSchema.Fingerprint {
+		return s.Decode(r)
+	}
+
+	n, err := writer.record("snapshot")
+	if err != nil {
+		return err
+	}
+	v, err := readValue(byteReader(r), n)
+	if err != nil {
+		return err
+	}
+	s.fromRecord(v)
+	return nil
+}
+
+// DecodeBatchWith reads the Snapshots of a batch written with the writer
+// schema, which may be an older or newer version than the current schema
+func DecodeBatchWith(r io.Reader, writer *Schema) ([]*Snapshot, error) {
+	if writer.Fingerprint == CurrentSchema.Fingerprint {
+		return DecodeBatch(r)
+	}
+
+	n, err := writer.record("batch")
+	if err != nil {
+		return nil, err
+	}
+	v, err := readValue(byteReader(r), n)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := v.(map[string]interface{})
+	items, _ := record["snapshots"].([]interface{})
+	snapshots := make([]*Snapshot, len(items))
+	for i, item := range items {
+		snapshots[i] = &Snapshot{}
+		snapshots[i].fromRecord(item)
+	}
+	return snapshots, nil
+}
+
+func (s *Snapshot) fromRecord(v interface{}) {
+	record, _ := v.(map[string]interface{})
+	s.Station = Station{
+		DataURL:   asString(record["data_url"]),
+		AliasURL:  asString(record["alias_url"]),
+		HumanURL:  asString(record["human_url"]),
+		Name:      asString(record["name"]),
+		NameCY:    asString(record["name_cy"]),
+		RiverName: asString(record["river_name"]),
+		Lat:       asFloat32(record["lat"]),
+		Lg:        asFloat32(record["lg"]),
+		Type:      asString(record["type"]),
+		Unit:      asString(record["unit"]),
+	}
+
+	readings, _ := record["readings"].([]interface{})
+	for _, r := range readings {
+		m, _ := r.(map[string]interface{})
+		s.Readings = append(s.Readings, Reading{
+			EventTime: time.Unix(asInt64(m["event_time"]), 0),
+			Value:     asFloat32(m["value"]),
+			Quality:   valueToQuality(asString(m["quality"])),
+			Source:    asString(m["source"]),
+		})
+	}
+
+	s.CorrelationID = asString(record["correlation_id"])
+	s.CausationID = asString(record["causation_id"])
+	s.ProcessedTime = time.Unix(asInt64(record["processed_time"]), 0)
+}
+
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func asInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	return 0
+}
+
+func asFloat32(v interface{}) float32 {
+	switch n := v.(type) {
+	case float32:
+		return n
+	case float64:
+		return float32(n)
+	case int32:
+		return float32(n)
+	case int64:
+		return float32(n)
+	}
+	return 0
+}
+
+type avroReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func byteReader(r io.Reader) avroReader {
+	if br, ok := r.(avroReader); ok {
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+// readValue decodes Avro binary data of any type as generic values, with a
+// record as a map of field names and an enum as its symbol
+func readValue(r avroReader, n *schemaNode) (interface{}, error) {
+	switch n.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := r.ReadByte()
+		return b == 1, err
+	case "int":
+		v, err := binary.ReadVarint(r)
+		return int32(v), err
+	case "long":
+		return binary.ReadVarint(r)
+	case "float":
+		var b [4]byte
+		_, err := io.ReadFull(r, b[:])
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[:])), err
+	case "double":
+		var b [8]byte
+		_, err := io.ReadFull(r, b[:])
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), err
+	case "bytes", "string":
+		b, err := readBytes(r)
+		if n.Type == "string" {
+			return string(b), err
+		}
+		return b, err
+	case "fixed":
+		b := make([]byte, n.Size)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	case "enum":
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(n.Symbols) {
+			return nil, errors.New("invalid symbol index for " + n.Name)
+		}
+		return n.Symbols[i], nil
+	case "union":
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(n.Branches) {
+			return nil, errors.New("invalid union branch index")
+		}
+		return readValue(r, n.Branches[i])
+	case "record":
+		record := make(map[string]interface{}, len(n.Fields))
+		for _, f := range n.Fields {
+			v, err := readValue(r, f.Type)
+			if err != nil {
+				return nil, err
+			}
+			record[f.Name] = v
+		}
+		return record, nil
+	case "array":
+		var items []interface{}
+		err := readBlocks(r, func() error {
+			v, err := readValue(r, n.Items)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case "map":
+		values := make(map[string]interface{})
+		err := readBlocks(r, func() error {
+			k, err := readBytes(r)
+			if err != nil {
+				return err
+			}
+			v, err := readValue(r, n.Values)
+			values[string(k)] = v
+			return err
+		})
+		return values, err
+	}
+	return nil, errors.New("unsupported type " + n.Type)
+}
+
+func readBytes(r avroReader) ([]byte, error) {
+	size, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 || size > math.MaxInt32 {
+		return nil, errors.New("invalid length")
+	}
+	b := make([]byte, size)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+// readBlocks reads the blocks of an array or map until the zero count
+func readBlocks(r avroReader, fn func() error) error {
+	for {
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// a negative count is followed by the block size in bytes
+			count = -count
+			if _, err := binary.ReadVarint(r); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package gauge
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Schema is a parsed version of the gauge.avsc Avro schema
+//
+// The Fingerprint is the CRC-64-AVRO fingerprint of the schema's Parsing
+// Canonical Form, so it is unchanged by docs, defaults or whitespace.
+type Schema struct {
+	Version     int
+	Fingerprint uint64
+	Canonical   string
+
+	names map[string]*schemaNode
+}
+
+type schemaNode struct {
+	Type     string // primitive, or record, enum, array, map, fixed or union
+	Name     string // full name of a named type
+	Fields   []schemaField
+	Symbols  []string
+	Items    *schemaNode
+	Values   *schemaNode
+	Size     int
+	Branches []*schemaNode
+}
+
+type schemaField struct {
+	Name string
+	Type *schemaNode
+}
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// ParseSchema parses an Avro schema definition
+func ParseSchema(version int, b []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	s := &Schema{
+		Version: version,
+		names:   make(map[string]*schemaNode),
+	}
+	root, err := s.parse(raw, "")
+	if err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	writeCanonical(&sb, root, make(map[string]bool))
+	s.Canonical = sb.String()
+	s.Fingerprint = fingerprint64([]byte(s.Canonical))
+	return s, nil
+}
+
+// record provides the named record type, e.g. snapshot
+func (s *Schema) record(name string) (*schemaNode, error) {
+	n, ok := s.names[fullName(name, avroNamespace)]
+	if !ok || n.Type != "record" {
+		return nil, errors.New("no " + name + " record in schema version " + strconv.Itoa(s.Version))
+	}
+	return n, nil
+}
+
+const avroNamespace = "com.rainchasers.gauge"
+
+func fullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func (s *Schema) parse(raw interface{}, namespace string) (*schemaNode, error) {
+	switch v := raw.(type) {
+	case string:
+		if primitiveTypes[v] {
+			return &schemaNode{Type: v}, nil
+		}
+		n, ok := s.names[fullName(v, namespace)]
+		if !ok {
+			return nil, errors.New("unknown type " + v)
+		}
+		return n, nil
+
+	case []interface{}:
+		n := &schemaNode{Type: "union"}
+		for _, b := range v {
+			branch, err := s.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			n.Branches = append(n.Branches, branch)
+		}
+		return n, nil
+
+	case map[string]interface{}:
+		return s.parseComplex(v, namespace)
+	}
+	return nil, errors.New("invalid schema type")
+}
+
+func (s *Schema) parseComplex(v map[string]interface{}, namespace string) (*schemaNode, error) {
+	typ, _ := v["type"].(string)
+	if ns, ok := v["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if name == "" {
+			return nil, errors.New(typ + " has no name")
+		}
+		n := &schemaNode{Type: typ, Name: fullName(name, namespace)}
+		if typ == "error" {
+			n.Type = "record"
+		}
+		if i := strings.LastIndex(n.Name, "."); i >= 0 {
+			namespace = n.Name[:i]
+		}
+		// register before the fields so a record can refer to itself
+		s.names[n.Name] = n
+
+		switch n.Type {
+		case "record":
+			fields, _ := v["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, errors.New("invalid field in " + n.Name)
+				}
+				fname, _ := fm["name"].(string)
+				ftype, err := s.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, err
+				}
+				n.Fields = append(n.Fields, schemaField{Name: fname, Type: ftype})
+			}
+		case "enum":
+			symbols, _ := v["symbols"].([]interface{})
+			for _, sym := range symbols {
+				str, _ := sym.(string)
+				n.Symbols = append(n.Symbols, str)
+			}
+		case "fixed":
+			size, _ := v["size"].(float64)
+			n.Size = int(size)
+		}
+		return n, nil
+
+	case "array":
+		items, err := s.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schemaNode{Type: "array", Items: items}, nil
+
+	case "map":
+		values, err := s.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &schemaNode{Type: "map", Values: values}, nil
+	}
+
+	if primitiveTypes[typ] {
+		return &schemaNode{Type: typ}, nil
+	}
+	return nil, errors.New("unknown type " + typ)
+}
+
+// writeCanonical writes the Parsing Canonical Form, with a named type in
+// full the first time it appears and by name after that
+func writeCanonical(sb *strings.Builder, n *schemaNode, isWritten map[string]bool) {
+	quote := func(s string) {
+		b, _ := json.Marshal(s)
+		sb.Write(b)
+	}
+
+	switch n.Type {
+	case "union":
+		sb.WriteString("[")
+		for i, b := range n.Branches {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			writeCanonical(sb, b, isWritten)
+		}
+		sb.WriteString("]")
+		return
+	case "array":
+		sb.WriteString(`{"type":"array","items":`)
+		writeCanonical(sb, n.Items, isWritten)
+		sb.WriteString("}")
+		return
+	case "map":
+		sb.WriteString(`{"type":"map","values":`)
+		writeCanonical(sb, n.Values, isWritten)
+		sb.WriteString("}")
+		return
+	}
+	if n.Name == "" {
+		quote(n.Type)
+		return
+	}
+	if isWritten[n.Name] {
+		quote(n.Name)
+		return
+	}
+	isWritten[n.Name] = true
+
+	sb.WriteString(`{"name":`)
+	quote(n.Name)
+	sb.WriteString(`,"type":`)
+	quote(n.Type)
+	switch n.Type {
+	case "record":
+		sb.WriteString(`,"fields":[`)
+		for i, f := range n.Fields {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(`{"name":`)
+			quote(f.Name)
+			sb.WriteString(`,"type":`)
+			writeCanonical(sb, f.Type, isWritten)
+			sb.WriteString("}")
+		}
+		sb.WriteString("]")
+	case "enum":
+		sb.WriteString(`,"symbols":[`)
+		for i, sym := range n.Symbols {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			quote(sym)
+		}
+		sb.WriteString("]")
+	case "fixed":
+		sb.WriteString(`,"size":` + strconv.Itoa(n.Size))
+	}
+	sb.WriteString("}")
+}
+
+// fingerprint64 is the CRC-64-AVRO (Rabin) fingerprint from the Avro spec
+func fingerprint64(b []byte) uint64 {
+	fp := fingerprintEmpty
+	for _, c := range b {
+		fp = (fp >> 8) ^ fingerprintTable[byte(fp)^c]
+	}
+	return fp
+}
+
+const fingerprintEmpty uint64 = 0xc15d213aa4d7a795
+
+var fingerprintTable = func() [256]uint64 {
+	var t [256]uint64
+	for i := range t {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (fingerprintEmpty & -(fp & 1))
+		}
+		t[i] = fp
+	}
+	return t
+}()
@@ -17,14 +17,39 @@ func typeToValue(t string) avro.TypeValues {
 		return avro.Temperature
 	case "rainfall":
 		return avro.Rainfall
+	case "tidal":
+		return avro.Tidal
 	}
 	return avro.Level
 }
 
+func qualityToValue(q string) avro.QualityValues {
+	switch q {
+	case QualitySuspect:
+		return avro.Suspect
+	case QualityEstimated:
+		return avro.Estimated
+	case QualityProvisional:
+		return avro.Provisional
+	case QualityValidated:
+		return avro.Validated
+	}
+	return avro.Unknown
+}
+
+func valueToQuality(v string) string {
+	if v == avro.Unknown.String() {
+		return ""
+	}
+	return v
+}
+
 func readingToAvro(r *Reading) *avro.Measure {
 	m := avro.NewMeasure()
 	m.Event_time = r.EventTime.Unix()
 	m.Value = r.Value
+	m.Quality = qualityToValue(r.Quality)
+	m.Source = r.Source
 	return m
 }
 
@@ -34,6 +59,7 @@ func snapshotToAvro(s *Snapshot) *avro.Snapshot {
 	a.Alias_url = s.Station.AliasURL
 	a.Human_url = s.Station.HumanURL
 	a.Name = s.Station.Name
+	a.Name_cy = s.Station.NameCY
 	a.River_name = s.Station.RiverName
 	a.Lat = s.Station.Lat
 	a.Lg = s.Station.Lg
@@ -61,12 +87,27 @@ func (s *Snapshot) Encode(w io.Writer) error {
 	return nil
 }
 
+// EncodeBatch writes out several Snapshots as one AVRO binary format batch
+func EncodeBatch(w io.Writer, snapshots []*Snapshot) error {
+	a := avro.NewBatch()
+	for _, s := range snapshots {
+		a.Snapshots = append(a.Snapshots, snapshotToAvro(s))
+	}
+
+	if err := a.Serialize(w); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func avroToStation(a *avro.Snapshot) Station {
 	return Station{
 		DataURL:   a.Data_url,
 		AliasURL:  a.Alias_url,
 		HumanURL:  a.Human_url,
 		Name:      a.Name,
+		NameCY:    a.Name_cy,
 		RiverName: a.River_name,
 		Lat:       a.Lat,
 		Lg:        a.Lg,
@@ -79,6 +120,8 @@ func avroToReading(a *avro.Measure) Reading {
 	return Reading{
 		EventTime: time.Unix(a.Event_time, 0),
 		Value:     a.Value,
+		Quality:   valueToQuality(a.Quality.String()),
+		Source:    a.Source,
 	}
 }
 
@@ -89,6 +132,26 @@ func (s *Snapshot) Decode(r io.Reader) error {
 		return err
 	}
 
+	s.fromAvro(a)
+	return nil
+}
+
+// DecodeBatch reads the Snapshots of an AVRO binary format batch
+func DecodeBatch(r io.Reader) ([]*Snapshot, error) {
+	a, err := avro.DeserializeBatch(r)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, len(a.Snapshots))
+	for i, as := range a.Snapshots {
+		snapshots[i] = &Snapshot{}
+		snapshots[i].fromAvro(as)
+	}
+	return snapshots, nil
+}
+
+func (s *Snapshot) fromAvro(a *avro.Snapshot) {
 	s.Station = avroToStation(a)
 	for _, m := range a.Readings {
 		s.Readings = append(s.Readings, avroToReading(m))
@@ -97,6 +160,4 @@ func (s *Snapshot) Decode(r io.Reader) error {
 	s.CorrelationID = a.Correlation_id
 	s.CausationID = a.Causation_id
 	s.ProcessedTime = time.Unix(a.Processed_time, 0)
-
-	return nil
 }
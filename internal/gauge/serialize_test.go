@@ -12,6 +12,7 @@ func TestEncodeDecode(t *testing.T) {
 		AliasURL:  "rloi://1234",
 		HumanURL:  "http://environment.data.gov.uk/flood-monitoring/id/stations/1029TH",
 		Name:      "Bourton Dickler",
+		NameCY:    "Bourton Dickler (CY)",
 		RiverName: "Dikler",
 		Lat:       51.874767,
 		Lg:        -1.740083,
@@ -23,6 +24,8 @@ func TestEncodeDecode(t *testing.T) {
 	readings = append(readings, Reading{
 		EventTime: timestamp.Add(time.Second),
 		Value:     1.23,
+		Quality:   QualityProvisional,
+		Source:    "ea-recent",
 	})
 	readings = append(readings, Reading{
 		EventTime: timestamp.Add(time.Second * 10),
@@ -61,6 +64,9 @@ func TestEncodeDecode(t *testing.T) {
 	if before.Station.Name != after.Station.Name {
 		t.Error("Name mis-match", after)
 	}
+	if before.Station.NameCY != after.Station.NameCY {
+		t.Error("Welsh name mis-match", after)
+	}
 	if before.Station.RiverName != after.Station.RiverName {
 		t.Error("River name mis-match", after)
 	}
@@ -90,6 +96,9 @@ func TestEncodeDecode(t *testing.T) {
 		if b.Value != a.Value {
 			t.Error("Value mis-match", i, b.Value, a.Value)
 		}
+		if b.Quality != a.Quality || b.Source != a.Source {
+			t.Error("Quality mis-match", i, b, a)
+		}
 	}
 
 	if !before.ProcessedTime.Equal(after.ProcessedTime) {
@@ -102,3 +111,60 @@ func TestEncodeDecode(t *testing.T) {
 		t.Error("CausationID mis-match", after)
 	}
 }
+
+func TestEncodeDecodeTypes(t *testing.T) {
+	for _, typ := range []string{"level", "flow", "temperature", "rainfall", "tidal"} {
+		before := Snapshot{Station: Station{Type: typ}}
+		var bb bytes.Buffer
+		if err := before.Encode(&bb); err != nil {
+			t.Fatal(err)
+		}
+		after := Snapshot{}
+		if err := after.Decode(&bb); err != nil {
+			t.Fatal(err)
+		}
+		if after.Station.Type != typ {
+			t.Error("Type mis-match", typ, after.Station.Type)
+		}
+	}
+}
+
+func TestEncodeDecodeBatch(t *testing.T) {
+	timestamp, _ := time.Parse(time.RFC3339, "2016-01-01T10:30:00Z")
+	before := []*Snapshot{
+		{
+			Station:       Station{DataURL: "http://example.com/1", Type: "level"},
+			Readings:      []Reading{{EventTime: timestamp, Value: 1.23}},
+			CorrelationID: "ABCDE",
+		},
+		{
+			Station:  Station{DataURL: "http://example.com/2", Type: "flow"},
+			Readings: []Reading{{EventTime: timestamp, Value: 4.56}, {EventTime: timestamp.Add(time.Minute), Value: 7.89}},
+		},
+	}
+
+	var bb bytes.Buffer
+	if err := EncodeBatch(&bb, before); err != nil {
+		t.Fatal(err)
+	}
+	after, err := DecodeBatch(&bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after) != len(before) {
+		t.Fatal("length mismatch", len(before), len(after))
+	}
+	for i, b := range before {
+		a := after[i]
+		if a.Station.DataURL != b.Station.DataURL || a.Station.Type != b.Station.Type {
+			t.Error("Station mis-match", i, a.Station)
+		}
+		if len(a.Readings) != len(b.Readings) || a.Readings[0].Value != b.Readings[0].Value {
+			t.Error("Readings mis-match", i, a.Readings)
+		}
+		if a.CorrelationID != b.CorrelationID {
+			t.Error("CorrelationID mis-match", i, a.CorrelationID)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package history keeps the long-term reading time-series of each station
+package history
+
+import (
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// Resolution is the time granularity of a series
+type Resolution string
+
+// Every reading is kept at Raw resolution (typically 15 minutes apart), and
+// downsampled into Hourly and Daily buckets.
+const (
+	Raw    Resolution = "raw"
+	Hourly Resolution = "hourly"
+	Daily  Resolution = "daily"
+)
+
+// Resolutions are all the resolutions, finest first
+var Resolutions = []Resolution{Raw, Hourly, Daily}
+
+// Bucket is the width of a downsampled point, zero for raw readings
+func (r Resolution) Bucket() time.Duration {
+	switch r {
+	case Hourly:
+		return time.Hour
+	case Daily:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// ResolutionFor picks the finest resolution that keeps a query between
+// from and to to a reasonable number of points
+func ResolutionFor(from time.Time, to time.Time) Resolution {
+	span := to.Sub(from)
+	switch {
+	case span <= 7*24*time.Hour:
+		return Raw
+	case span <= 90*24*time.Hour:
+		return Hourly
+	}
+	return Daily
+}
+
+// Retention is how long each resolution is kept, where zero is forever
+type Retention map[Resolution]time.Duration
+
+// DefaultRetention keeps raw readings for a month, hourly for a year and a
+// bit (for year on year comparison) and daily forever
+var DefaultRetention = Retention{
+	Raw:    31 * 24 * time.Hour,
+	Hourly: 400 * 24 * time.Hour,
+	Daily:  0,
+}
+
+// Point is a reading, or the summary of readings in a downsampled bucket
+//
+// EventTime is the start of a downsampled bucket, and Value the mean. Only
+// a raw reading has a quality and source.
+type Point struct {
+	EventTime time.Time `json:"time"`
+	Value     float32   `json:"value"`
+	Min       float32   `json:"min"`
+	Max       float32   `json:"max"`
+	Count     int       `json:"count"`
+	Quality   string    `json:"quality,omitempty"`
+	Source    string    `json:"source,omitempty"`
+}
+
+func pointFromReading(r gauge.Reading) Point {
+	return Point{
+		EventTime: r.EventTime.UTC(),
+		Value:     r.Value,
+		Min:       r.Value,
+		Max:       r.Value,
+		Count:     1,
+		Quality:   r.Quality,
+		Source:    r.Source,
+	}
+}
+
+// outranks is true if the raw point is of higher quality than another, as
+// with gauge readings
+func (p Point) outranks(other Point) bool {
+	return gauge.Reading{Quality: p.Quality}.Outranks(gauge.Reading{Quality: other.Quality})
+}
+
+// downsample summarises raw points (in time order) into a single point
+func downsample(start time.Time, raw []Point) Point {
+	p := Point{
+		EventTime: start,
+		Min:       raw[0].Value,
+		Max:       raw[0].Value,
+	}
+	var sum float64
+	for _, r := range raw {
+		sum += float64(r.Value)
+		if r.Value < p.Min {
+			p.Min = r.Value
+		}
+		if r.Value > p.Max {
+			p.Max = r.Value
+		}
+		p.Count++
+	}
+	p.Value = float32(sum / float64(p.Count))
+	return p
+}
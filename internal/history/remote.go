@@ -0,0 +1,96 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/robtuley/report"
+)
+
+// Querier provides the points of a station series, from a Store or from a
+// Remote served by the process that holds the Store open
+type Querier interface {
+	Query(ctx context.Context, dataURL string, res Resolution, from time.Time, to time.Time) ([]Point, report.Span)
+}
+
+// Handler serves the series of a Querier as JSON for a Remote, e.g.
+//
+//	/history?data_url=rloi://5020&resolution=hourly&from=&to=
+//
+// with from and to as RFC3339 times.
+func Handler(q Querier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.URL.Query()
+		from, err := time.Parse(time.RFC3339Nano, v.Get("from"))
+		if err != nil {
+			http.Error(w, "from must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		to, err := time.Parse(time.RFC3339Nano, v.Get("to"))
+		if err != nil {
+			http.Error(w, "to must be RFC3339", http.StatusBadRequest)
+			return
+		}
+
+		points, span := q.Query(r.Context(), v.Get("data_url"), Resolution(v.Get("resolution")), from, to)
+		if err := span.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if points == nil {
+			points = []Point{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(points)
+	})
+}
+
+// Remote queries the history served by Handler in another process
+type Remote struct {
+	URL    string // base URL the handler is served below, e.g. http://store:8081
+	Client *http.Client
+}
+
+// NewRemote creates a client of the history served below baseURL
+func NewRemote(baseURL string) *Remote {
+	return &Remote{
+		URL:    strings.TrimSuffix(baseURL, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Query provides the station series points at or after from and before to
+func (rm *Remote) Query(ctx context.Context, dataURL string, res Resolution, from time.Time, to time.Time) ([]Point, report.Span) {
+	span := report.StartSpan("history.remote.query").Field("data_url", dataURL)
+	span = span.Field("resolution", string(res))
+
+	q := url.Values{}
+	q.Set("data_url", dataURL)
+	q.Set("resolution", string(res))
+	q.Set("from", from.Format(time.RFC3339Nano))
+	q.Set("to", to.Format(time.RFC3339Nano))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rm.URL+"/history?"+q.Encode(), nil)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	resp, err := rm.Client.Do(req)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, span.End(errors.New("history responded " + resp.Status))
+	}
+
+	var points []Point
+	if err := json.NewDecoder(resp.Body).Decode(&points); err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("count_points", len(points))
+	return points, span.End()
+}
@@ -0,0 +1,49 @@
+package history
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestRemoteQueriesServedHistory(t *testing.T) {
+	ctx := context.Background()
+	hs, span := New(filepath.Join(t.TempDir(), "history.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Close()
+	dataURL := "rloi://5020"
+	eventTime := time.Date(2020, 10, 6, 22, 15, 0, 0, time.UTC)
+	err := hs.Append(ctx, dataURL, []gauge.Reading{
+		{EventTime: eventTime, Value: 1.5, Quality: "validated"},
+	}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(Handler(hs))
+	defer ts.Close()
+	rm := NewRemote(ts.URL + "/")
+
+	points, span := rm.Query(ctx, dataURL, Raw, eventTime.Add(-time.Hour), eventTime.Add(time.Hour))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || !points[0].EventTime.Equal(eventTime) || points[0].Value != 1.5 ||
+		points[0].Quality != "validated" {
+		t.Error("unexpected remote points", points)
+	}
+
+	points, span = rm.Query(ctx, "rloi://0", Hourly, eventTime.Add(-time.Hour), eventTime)
+	if err := span.Err(); err != nil || len(points) != 0 {
+		t.Error("expected no points for unknown station", points, err)
+	}
+	if _, span := rm.Query(ctx, dataURL, "weekly", eventTime, eventTime); span.Err() == nil {
+		t.Error("expected error for unknown resolution")
+	}
+}
@@ -0,0 +1,258 @@
+package history
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store persists station time-series in an embedded bbolt database file
+//
+// Each resolution is a bucket of per station DataURL buckets, keyed by the
+// big-endian unix time of each point. Downsampled points are recomputed
+// from the raw readings held for their bucket whenever a reading is added,
+// unless the raw readings of the bucket may have been pruned.
+// The database is held open until closed, so the file cannot be shared with
+// another process at the same time.
+type Store struct {
+	Path      string
+	Retention Retention
+	Now       func() time.Time // clock the raw retention is measured against
+
+	db *bolt.DB
+}
+
+// New opens the database file, creating it if it does not yet exist
+func New(path string) (*Store, report.Span) {
+	span := report.StartSpan("history.connect").Field("path", path)
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, span.End(err)
+	}
+	hs := &Store{
+		Path:      path,
+		Retention: DefaultRetention,
+		Now:       time.Now,
+		db:        db,
+	}
+	err = hs.update(func(tx *bolt.Tx) error {
+		for _, res := range Resolutions {
+			if _, err := tx.CreateBucketIfNotExists([]byte(res)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, span.End(err)
+	}
+	return hs, span.End()
+}
+
+// Close releases the database file
+func (hs *Store) Close() error {
+	return hs.db.Close()
+}
+
+// Append adds readings to the station series and updates the downsampled ones
+func (hs *Store) Append(ctx context.Context, dataURL string, readings []gauge.Reading) report.Span {
+	span := report.StartSpan("history.append").Field("data_url", dataURL)
+	span = span.Field("count_readings", len(readings))
+	if len(readings) == 0 {
+		return span.End()
+	}
+
+	err := hs.update(func(tx *bolt.Tx) error {
+		raw, err := seriesBucket(tx, Raw, dataURL)
+		if err != nil {
+			return err
+		}
+
+		// write each raw reading, noting the buckets that need recalculating
+		// (a reading only replaces one at the same time if of higher quality,
+		// so a later provisional publish cannot overwrite a validated point)
+		touched := make(map[Resolution]map[int64]bool)
+		for _, res := range Resolutions[1:] {
+			touched[res] = make(map[int64]bool)
+		}
+		for _, r := range readings {
+			p := pointFromReading(r)
+			if v := raw.Get(timeKey(p.EventTime)); v != nil {
+				var existing Point
+				if err := json.Unmarshal(v, &existing); err != nil {
+					return err
+				}
+				if !p.outranks(existing) {
+					continue
+				}
+			}
+			if err := putPoint(raw, p); err != nil {
+				return err
+			}
+			for res, starts := range touched {
+				starts[p.EventTime.Truncate(res.Bucket()).Unix()] = true
+			}
+		}
+
+		// recompute the downsampled points from the raw readings, but keep
+		// an existing point older than the raw retention as it may summarise
+		// readings that have since been pruned (so a late reading there only
+		// fills a missing point)
+		var isPruned func(time.Time) bool
+		if keep := hs.Retention[Raw]; keep > 0 {
+			threshold := hs.Now().Add(-keep)
+			isPruned = func(t time.Time) bool { return t.Before(threshold) }
+		} else {
+			isPruned = func(t time.Time) bool { return false }
+		}
+		for res, starts := range touched {
+			b, err := seriesBucket(tx, res, dataURL)
+			if err != nil {
+				return err
+			}
+			for start := range starts {
+				from := time.Unix(start, 0).UTC()
+				if isPruned(from) && b.Get(timeKey(from)) != nil {
+					continue
+				}
+				points, err := rangePoints(raw, from, from.Add(res.Bucket()))
+				if err != nil {
+					return err
+				}
+				if len(points) == 0 {
+					continue
+				}
+				if err := putPoint(b, downsample(from, points)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return span.End(err)
+}
+
+// Query provides the station series points at or after from and before to
+func (hs *Store) Query(ctx context.Context, dataURL string, res Resolution, from time.Time, to time.Time) ([]Point, report.Span) {
+	span := report.StartSpan("history.query").Field("data_url", dataURL)
+	span = span.Field("resolution", string(res))
+
+	var points []Point
+	err := hs.view(func(tx *bolt.Tx) error {
+		parent := tx.Bucket([]byte(res))
+		if parent == nil {
+			return errors.New("unknown resolution " + string(res))
+		}
+		b := parent.Bucket([]byte(dataURL))
+		if b == nil {
+			return nil
+		}
+		var err error
+		points, err = rangePoints(b, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("count_points", len(points))
+	return points, span.End()
+}
+
+// Prune removes points that are older than the retention policy
+func (hs *Store) Prune(ctx context.Context, now time.Time) report.Span {
+	span := report.StartSpan("history.prune")
+
+	count := 0
+	err := hs.update(func(tx *bolt.Tx) error {
+		for _, res := range Resolutions {
+			keep := hs.Retention[res]
+			if keep == 0 {
+				continue
+			}
+			threshold := timeKey(now.Add(-keep))
+
+			parent := tx.Bucket([]byte(res))
+			if parent == nil {
+				continue
+			}
+			// the parent must not be modified while iterating its keys
+			var names [][]byte
+			err := parent.ForEach(func(name []byte, v []byte) error {
+				names = append(names, append([]byte(nil), name...))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				b := parent.Bucket(name)
+				if b == nil {
+					continue
+				}
+				c := b.Cursor()
+				for k, _ := c.First(); k != nil && string(k) < string(threshold); k, _ = c.First() {
+					if err := c.Delete(); err != nil {
+						return err
+					}
+					count++
+				}
+			}
+		}
+		return nil
+	})
+	span = span.Field("count_removed", count)
+	return span.End(err)
+}
+
+func (hs *Store) update(fn func(tx *bolt.Tx) error) error {
+	return hs.db.Update(fn)
+}
+
+func (hs *Store) view(fn func(tx *bolt.Tx) error) error {
+	return hs.db.View(fn)
+}
+
+func seriesBucket(tx *bolt.Tx, res Resolution, dataURL string) (*bolt.Bucket, error) {
+	parent, err := tx.CreateBucketIfNotExists([]byte(res))
+	if err != nil {
+		return nil, err
+	}
+	return parent.CreateBucketIfNotExists([]byte(dataURL))
+}
+
+func putPoint(b *bolt.Bucket, p Point) error {
+	v, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return b.Put(timeKey(p.EventTime), v)
+}
+
+func rangePoints(b *bolt.Bucket, from time.Time, to time.Time) ([]Point, error) {
+	var points []Point
+	max := string(timeKey(to))
+	c := b.Cursor()
+	for k, v := c.Seek(timeKey(from)); k != nil && string(k) < max; k, v = c.Next() {
+		var p Point
+		if err := json.Unmarshal(v, &p); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// timeKey sorts in time order for any time after 1970
+func timeKey(t time.Time) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(t.Unix()))
+	return k
+}
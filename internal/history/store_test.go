@@ -0,0 +1,164 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestDownsamplingAndRetention(t *testing.T) {
+	ctx := context.Background()
+	hs, span := New(filepath.Join(t.TempDir(), "history.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Close()
+	dataURL := "http://example.com/station/1"
+	start := time.Date(2020, 10, 6, 0, 0, 0, 0, time.UTC)
+
+	// two days of 15 minute readings, with the second day delivered twice
+	var readings []gauge.Reading
+	for i := 0; i < 2*24*4; i++ {
+		readings = append(readings, gauge.Reading{
+			EventTime: start.Add(time.Duration(i) * 15 * time.Minute),
+			Value:     float32(i % 4),
+		})
+	}
+	for _, batch := range [][]gauge.Reading{readings, readings[24*4:]} {
+		if err := hs.Append(ctx, dataURL, batch).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	end := start.Add(48 * time.Hour)
+	raw, span := hs.Query(ctx, dataURL, Raw, start, end)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != len(readings) {
+		t.Error("expected every raw reading, got", len(raw))
+	}
+
+	hourly, _ := hs.Query(ctx, dataURL, Hourly, start, end)
+	if len(hourly) != 48 {
+		t.Fatal("expected 48 hourly points, got", len(hourly))
+	}
+	h := hourly[1]
+	if !h.EventTime.Equal(start.Add(time.Hour)) || h.Count != 4 || h.Value != 1.5 || h.Min != 0 || h.Max != 3 {
+		t.Error("unexpected hourly point", h)
+	}
+
+	daily, _ := hs.Query(ctx, dataURL, Daily, start, end)
+	if len(daily) != 2 || daily[1].Count != 96 {
+		t.Fatal("unexpected daily points", daily)
+	}
+
+	// after a month the raw readings expire but the downsampled remain
+	if err := hs.Prune(ctx, end.Add(DefaultRetention[Raw])).Err(); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = hs.Query(ctx, dataURL, Raw, start, end)
+	if len(raw) != 0 {
+		t.Error("expected raw readings to be pruned, got", len(raw))
+	}
+	hourly, _ = hs.Query(ctx, dataURL, Hourly, start, end)
+	if len(hourly) != 48 {
+		t.Error("expected hourly points to be retained, got", len(hourly))
+	}
+}
+
+func TestLateReadingKeepsPrunedAggregates(t *testing.T) {
+	ctx := context.Background()
+	hs, span := New(filepath.Join(t.TempDir(), "history.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Close()
+	dataURL := "http://example.com/station/1"
+	start := time.Date(2020, 10, 6, 0, 0, 0, 0, time.UTC)
+	now := start.Add(2 * time.Hour)
+	hs.Now = func() time.Time { return now }
+
+	// an hour of readings, then their raw readings expire
+	var readings []gauge.Reading
+	for i := 0; i < 4; i++ {
+		readings = append(readings, gauge.Reading{
+			EventTime: start.Add(time.Duration(i) * 15 * time.Minute),
+			Value:     float32(i),
+		})
+	}
+	if err := hs.Append(ctx, dataURL, readings).Err(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(DefaultRetention[Raw])
+	if err := hs.Prune(ctx, now).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a late reading for that hour, and for an hour with no readings yet
+	late := []gauge.Reading{
+		{EventTime: start.Add(5 * time.Minute), Value: 10},
+		{EventTime: start.Add(time.Hour), Value: 5},
+	}
+	if err := hs.Append(ctx, dataURL, late).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	hourly, _ := hs.Query(ctx, dataURL, Hourly, start, start.Add(2*time.Hour))
+	if len(hourly) != 2 {
+		t.Fatal("expected 2 hourly points, got", hourly)
+	}
+	if h := hourly[0]; h.Count != 4 || h.Value != 1.5 || h.Max != 3 {
+		t.Error("complete aggregate overwritten by late reading", h)
+	}
+	if h := hourly[1]; h.Count != 1 || h.Value != 5 {
+		t.Error("missing aggregate not filled by late reading", h)
+	}
+}
+
+func TestHigherQualityPointIsKept(t *testing.T) {
+	ctx := context.Background()
+	hs, span := New(filepath.Join(t.TempDir(), "history.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer hs.Close()
+	dataURL := "http://example.com/station/1"
+	start := time.Date(2020, 10, 6, 0, 0, 0, 0, time.UTC)
+	hs.Now = func() time.Time { return start }
+
+	for _, r := range []gauge.Reading{
+		{EventTime: start, Value: 1.23, Quality: gauge.QualityProvisional, Source: "ea-recent"},
+		{EventTime: start, Value: 1.25, Quality: gauge.QualityValidated, Source: "ea-hydrology"},
+		{EventTime: start, Value: 1.21, Quality: gauge.QualityProvisional, Source: "ea-recent"},
+	} {
+		if err := hs.Append(ctx, dataURL, []gauge.Reading{r}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, _ := hs.Query(ctx, dataURL, Raw, start, start.Add(time.Hour))
+	if len(raw) != 1 || raw[0].Value != 1.25 || raw[0].Quality != gauge.QualityValidated || raw[0].Source != "ea-hydrology" {
+		t.Fatal("expected validated point to be kept", raw)
+	}
+	hourly, _ := hs.Query(ctx, dataURL, Hourly, start, start.Add(time.Hour))
+	if len(hourly) != 1 || hourly[0].Value != 1.25 {
+		t.Error("expected hourly point of validated reading", hourly)
+	}
+}
+
+func TestResolutionFor(t *testing.T) {
+	now := time.Now()
+	if res := ResolutionFor(now.Add(-72*time.Hour), now); res != Raw {
+		t.Error("expected raw for 3 days, got", res)
+	}
+	if res := ResolutionFor(now.Add(-30*24*time.Hour), now); res != Hourly {
+		t.Error("expected hourly for a month, got", res)
+	}
+	if res := ResolutionFor(now.Add(-365*24*time.Hour), now); res != Daily {
+		t.Error("expected daily for a year, got", res)
+	}
+}
@@ -0,0 +1,128 @@
+package nrw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/report"
+)
+
+// Backfill publishes the recent time-series readings of each station
+// parameter, filling any gap left by an outage
+//
+// A zero length APIKey discovers stations from a recorded API response.
+type Backfill struct {
+	ProjectID           string
+	TopicName           string
+	QueueURL            string
+	APIKey              string
+	Days                int
+	AliasURLs           []string // zero length is every station
+	MaxPublishPerSecond int
+	Now                 func() time.Time // nil is time.Now
+}
+
+// Run publishes a multi-reading snapshot for each station parameter
+func (cfg Backfill) Run(ctx context.Context, d *daemon.Supervisor) error {
+	if cfg.Days < 1 {
+		return errors.New("backfill needs at least 1 day")
+	}
+	isDryRun := cfg.ProjectID == "" && cfg.QueueURL == ""
+	now := time.Now
+	if cfg.Now != nil {
+		now = cfg.Now
+	}
+	since := now().AddDate(0, 0, -cfg.Days)
+
+	// discover station parameters from the recent readings
+	var snapshots []gauge.Snapshot
+	var dSpan report.Span
+	if cfg.APIKey == "" {
+		var err error
+		dSpan = report.StartSpan("nrw.recent").Field("recorded", true)
+		snapshots, err = parseRecent(bytes.NewBufferString(jsonResponseFromAPI))
+		dSpan = dSpan.Field("snapshots_count", len(snapshots)).End(err)
+	} else {
+		snapshots, dSpan = recent(ctx, cfg.APIKey)
+	}
+	if err := dSpan.Err(); err != nil {
+		d.Trace(dSpan)
+		return err
+	}
+	stations := cfg.filter(snapshots)
+
+	// if dry run shorten the run
+	if isDryRun && len(stations) > 3 {
+		stations = stations[:3]
+	}
+
+	// open connection to pubsub
+	topic, cSpan := queue.Connect(ctx, cfg.QueueURL, cfg.ProjectID, cfg.TopicName)
+	d.Trace(dSpan.FollowedBy(cSpan))
+	if err := cSpan.Err(); err != nil {
+		return err
+	}
+	defer topic.Stop()
+
+	perSecond := cfg.MaxPublishPerSecond
+	if perSecond < 1 {
+		perSecond = 1
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(perSecond))
+	defer ticker.Stop()
+
+	for _, s := range stations {
+		readings, span := historic(ctx, cfg.APIKey, s.DataURL, since)
+		if err := span.Err(); err != nil {
+			d.Trace(span)
+			return err
+		}
+		if len(readings) == 0 {
+			d.Trace(span)
+			continue
+		}
+
+		pSpan := topic.Publish(ctx, &gauge.Snapshot{
+			Station:       s,
+			Readings:      readings,
+			CorrelationID: span.TraceID(),
+			CausationID:   span.SpanID(),
+		})
+		d.Trace(span.FollowedBy(pSpan))
+		if err := pSpan.Err(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// exit early on shutdown
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// filter provides the stations to backfill in discovery order
+func (cfg Backfill) filter(snapshots []gauge.Snapshot) []gauge.Station {
+	wanted := make(map[string]bool, len(cfg.AliasURLs))
+	for _, url := range cfg.AliasURLs {
+		wanted[url] = true
+	}
+
+	var stations []gauge.Station
+	for _, snap := range snapshots {
+		s := snap.Station
+		if len(wanted) > 0 && !wanted[s.AliasURL] && !wanted[s.DataURL] {
+			continue
+		}
+		stations = append(stations, s)
+	}
+	return stations
+}
@@ -0,0 +1,69 @@
+package nrw
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+func TestBackfillPublishesMultiReadingSnapshots(t *testing.T) {
+	// replay the time-series response from a fixture named after the URL
+	dir := t.TempDir()
+	b, err := ioutil.ReadFile("testdata/historic.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := historicURL + "?location=1000&parameter=10095"
+	if err := ioutil.WriteFile(filepath.Join(dir, daemon.FixtureName(url)), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	daemon.Replay(dir)
+
+	broker := queue.SharedMemory("nrw-backfill")
+	broker.CreateGroup("test")
+
+	cfg := Backfill{
+		QueueURL:            "mem://nrw-backfill",
+		Days:                2,
+		AliasURLs:           []string{"rloi://1000/10095"},
+		MaxPublishPerSecond: 100,
+		Now: func() time.Time {
+			return time.Date(2020, 10, 6, 22, 0, 0, 0, time.UTC)
+		},
+	}
+	d := daemon.New("test")
+	d.Run(context.Background(), func(ctx context.Context, d *daemon.Supervisor) error {
+		defer d.Close()
+		return cfg.Run(ctx, d)
+	})
+	d.CloseAfter(5 * time.Second)
+	d.Wait()
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var snapshots []*gauge.Snapshot
+	queue.NewTopic(broker).Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		snapshots = append(snapshots, s)
+		if broker.Idle() {
+			cancel()
+		}
+		return err
+	})
+
+	if len(snapshots) != 1 {
+		t.Fatal("expected 1 snapshot, got", len(snapshots))
+	}
+	s := snapshots[0]
+	if s.Station.DataURL != "rloi://1000/10095" || s.Station.Type != "rainfall" || len(s.Readings) != 3 {
+		t.Error("unexpected snapshot", s)
+	}
+}
@@ -0,0 +1,89 @@
+package nrw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+type historicJSON struct {
+	Readings []struct {
+		Time  customTime `json:"time"`
+		Value *float32   `json:"value"`
+	} `json:"parameterReadings"`
+}
+
+const historicURL = recentURL + "/historical"
+
+// historicSource is the feed of time-series telemetry
+const historicSource = "nrw-historic"
+
+// historicDataURL is the time-series URL for a parameter data URL such as
+// rloi://4155/10320
+func historicDataURL(dataURL string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(dataURL, "rloi://"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", errors.New("no parameter in data URL " + dataURL)
+	}
+	return historicURL + "?location=" + parts[0] + "&parameter=" + parts[1], nil
+}
+
+// historic fetches the NRW time-series readings of a station parameter
+// since a point in time
+func historic(ctx context.Context, apiKey string, dataURL string, since time.Time) ([]gauge.Reading, report.Span) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	span := report.StartSpan("nrw.historic").Field("data_url", dataURL)
+	url, err := historicDataURL(dataURL)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	span = span.Field("url", url)
+
+	resp, err := daemon.AuthorisedJSON(ctx, url, recentKeyHeader, apiKey)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+
+	readings, err := parseHistoric(resp.Body, since)
+	span = span.Field("readings_count", len(readings))
+	return readings, span.End(err)
+}
+
+// parseHistoric provides the readings since a point in time in time order,
+// skipping any without a value
+func parseHistoric(r io.Reader, since time.Time) ([]gauge.Reading, error) {
+	var readings []gauge.Reading
+
+	parsed := historicJSON{}
+	if err := json.NewDecoder(r).Decode(&parsed); err != nil {
+		return readings, err
+	}
+
+	for _, v := range parsed.Readings {
+		if v.Value == nil || !v.Time.IsSet() || v.Time.Before(since) {
+			continue
+		}
+		readings = append(readings, gauge.Reading{
+			EventTime: v.Time.Time,
+			Value:     *v.Value,
+			Quality:   gauge.QualityProvisional,
+			Source:    historicSource,
+		})
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].EventTime.Before(readings[j].EventTime)
+	})
+
+	return readings, nil
+}
@@ -0,0 +1,50 @@
+package nrw
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestHistoricDataURL(t *testing.T) {
+	url, err := historicDataURL("rloi://4155/10320")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != historicURL+"?location=4155&parameter=10320" {
+		t.Error("unexpected URL", url)
+	}
+	if _, err := historicDataURL("rloi://4155"); err == nil {
+		t.Error("expected error without a parameter")
+	}
+}
+
+func TestHistoricParse(t *testing.T) {
+	f, err := os.Open("testdata/historic.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	since := time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC)
+	readings, err := parseHistoric(f, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 3 {
+		t.Fatal("expected 3 readings, got", readings)
+	}
+	for i := 1; i < len(readings); i++ {
+		if !readings[i].EventTime.After(readings[i-1].EventTime) {
+			t.Error("readings out of order", readings)
+		}
+	}
+	if readings[0].Value != 0.4 {
+		t.Error("unexpected first reading", readings[0])
+	}
+	if readings[0].Quality != gauge.QualityProvisional || readings[0].Source != "nrw-historic" {
+		t.Error("unexpected quality", readings[0])
+	}
+}
@@ -0,0 +1,158 @@
+package nrw
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/dedupe"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/report"
+)
+
+// Poller publishes recent NRW readings to the queue on a refresh schedule
+//
+// A zero length APIKey uses a recorded API response rather than the live API.
+// Priority stations are published first each cycle, and the rest only on
+// the slower background refresh period.
+type Poller struct {
+	ProjectID                        string
+	TopicName                        string
+	QueueURL                         string
+	APIKey                           string
+	RefreshPeriodInSeconds           int
+	PriorityURLs                     []string // data, alias or human URLs
+	BackgroundRefreshPeriodInSeconds int      // zero is the refresh period
+	MaxPublishPerSecond              int
+	StatePath                        string // blank keeps the dedupe state in memory only
+	ExitAfterXConsecutiveErr         int
+	Cycles                           int // zero polls until shutdown
+}
+
+// Run polls and publishes readings until shutdown or the cycles are complete
+func (cfg Poller) Run(ctx context.Context, d *daemon.Supervisor) error {
+	// open connection to pubsub
+	topic, span := queue.Connect(ctx, cfg.QueueURL, cfg.ProjectID, cfg.TopicName)
+	d.Trace(span)
+	if err := span.Err(); err != nil {
+		return err
+	}
+	defer topic.Stop()
+
+	// only publish readings newer than those already published
+	seen, sSpan := dedupe.New(cfg.StatePath)
+	d.Trace(sSpan)
+	if err := sSpan.Err(); err != nil {
+		return err
+	}
+	defer func() { d.Trace(seen.Save()) }()
+
+	priority := gauge.NewPriority(cfg.PriorityURLs)
+	backgroundEvery := gauge.BackgroundEvery(cfg.RefreshPeriodInSeconds, cfg.BackgroundRefreshPeriodInSeconds)
+
+	nConsecutiveErr := 0
+	nCycles := 0
+pollLoop:
+	for {
+		err := func() error {
+			// get recent stations & readings
+			var snapshots []gauge.Snapshot
+			var err error
+			var span report.Span
+			if cfg.APIKey == "" {
+				snapshots, err = parseRecent(bytes.NewBufferString(jsonResponseFromAPI))
+			} else {
+				snapshots, span = recent(ctx, cfg.APIKey)
+				d.Trace(span)
+				err = span.Err()
+			}
+			if err != nil {
+				return err
+			}
+
+			// calculate update rate to refresh on schedule
+			snapshots = dueSnapshots(snapshots, priority, nCycles, backgroundEvery)
+			every := cfg.durationBetweenPublish(len(snapshots))
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+
+			// publish snapshots
+			for _, s := range snapshots {
+				span := seen.Publish(ctx, topic, &s)
+				d.Trace(span)
+				if err := span.Err(); err != nil {
+					return err
+				}
+
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+
+			return nil
+		}()
+
+		// exit loop if shutdown
+		select {
+		case <-ctx.Done():
+			break pollLoop
+		default:
+		}
+
+		// track consecutive errors
+		if err != nil {
+			nConsecutiveErr++
+			if nConsecutiveErr >= cfg.ExitAfterXConsecutiveErr {
+				// ignore a few isolated errors, but if
+				// many consecutive bubble up to restart
+				return err
+			}
+		} else {
+			nConsecutiveErr = 0
+		}
+
+		// exit loop once all cycles are done
+		nCycles++
+		if cfg.Cycles > 0 && nCycles >= cfg.Cycles {
+			break pollLoop
+		}
+	}
+
+	return nil
+}
+
+// dueSnapshots provides the snapshots due a publish this cycle, with the
+// priority stations first
+func dueSnapshots(snapshots []gauge.Snapshot, priority gauge.Priority, cycle int, backgroundEvery int) []gauge.Snapshot {
+	var first, rest []gauge.Snapshot
+	for _, s := range snapshots {
+		if !priority.IsDue(s.Station, cycle, backgroundEvery) {
+			continue
+		}
+		if priority.Contains(s.Station) {
+			first = append(first, s)
+		} else {
+			rest = append(rest, s)
+		}
+	}
+	return append(first, rest...)
+}
+
+func (cfg Poller) durationBetweenPublish(total int) time.Duration {
+	if total == 0 {
+		total = 1
+	}
+	ms := cfg.RefreshPeriodInSeconds * 1000 / total
+	min := 1
+	if cfg.MaxPublishPerSecond > 0 {
+		min = 1000 / cfg.MaxPublishPerSecond
+	}
+	if ms < min {
+		ms = min
+	}
+	return time.Millisecond * time.Duration(ms)
+}
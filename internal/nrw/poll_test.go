@@ -1,4 +1,4 @@
-package main
+package nrw
 
 import (
 	"context"
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
 	"go.uber.org/goleak"
 )
 
@@ -16,7 +17,7 @@ func TestDryRun(t *testing.T) {
 	defer http.DefaultTransport.(*http.Transport).CloseIdleConnections()
 
 	// define an accelerated dry run
-	cfg := config{
+	cfg := Poller{
 		RefreshPeriodInSeconds: 5,
 	}
 
@@ -26,7 +27,7 @@ func TestDryRun(t *testing.T) {
 	d := daemon.New("example")
 
 	// perform the dry run
-	err := cfg.run(ctx, d)
+	err := cfg.Run(ctx, d)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,3 +48,21 @@ func TestDryRun(t *testing.T) {
 	}
 	d.CloseWait()
 }
+
+func TestDueSnapshotsPublishesPriorityFirst(t *testing.T) {
+	snapshots := []gauge.Snapshot{
+		{Station: gauge.Station{AliasURL: "rloi://1"}},
+		{Station: gauge.Station{AliasURL: "rloi://2"}},
+		{Station: gauge.Station{AliasURL: "rloi://3"}},
+	}
+	priority := gauge.NewPriority([]string{"rloi://3"})
+
+	due := dueSnapshots(snapshots, priority, 0, 3)
+	if len(due) != 3 || due[0].Station.AliasURL != "rloi://3" {
+		t.Error("unexpected first cycle", due)
+	}
+	due = dueSnapshots(snapshots, priority, 2, 3)
+	if len(due) != 1 || due[0].Station.AliasURL != "rloi://3" {
+		t.Error("unexpected background cycle", due)
+	}
+}
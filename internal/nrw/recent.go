@@ -1,4 +1,4 @@
-package main
+package nrw
 
 import (
 	"context"
@@ -46,13 +46,16 @@ func (ct *customTime) IsSet() bool {
 }
 
 type recentJSON []struct {
-	ID     int    `json:"location"`
-	Title  string `json:"titleEN"`
-	Coords struct {
+	ID      int    `json:"location"`
+	Title   string `json:"titleEN"`
+	TitleCY string `json:"titleCy"`
+	NameCY  string `json:"nameCY"`
+	Coords  struct {
 		Lat float32 `json:"latitude"`
 		Lng float32 `json:"longitude"`
 	} `json:"coordinates"`
 	Parameters []struct {
+		ID          int        `json:"parameter"`
 		Name        string     `json:"paramNameEN"`
 		LatestValue float32    `json:"latestValue"`
 		LatestTime  customTime `json:"latestTime"`
@@ -63,6 +66,9 @@ type recentJSON []struct {
 const recentURL = "https://api.naturalresources.wales/rivers-and-seas/v1/api/StationData"
 const recentKeyHeader = "Ocp-Apim-Subscription-Key"
 
+// recentSource is the feed of latest telemetry, which is not yet validated
+const recentSource = "nrw-recent"
+
 // Recent fetches recent NRW readings
 func recent(ctx context.Context, apiKey string) ([]gauge.Snapshot, report.Span) {
 	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
@@ -115,44 +121,83 @@ func parseRecent(r io.Reader) ([]gauge.Snapshot, error) {
 	}
 
 	for _, feature := range parsed {
-		if len(feature.Parameters) != 1 {
-			continue
-		}
-		parameters := feature.Parameters[0]
 		rtoi := strconv.Itoa(feature.ID)
-
-		var stationType string
-		switch parameters.Name {
-		case "Rainfall":
-			stationType = "rainfall"
-		case "River Level":
-			stationType = "level"
-		default:
-			continue
+		nameCY := feature.TitleCY
+		if nameCY == "" {
+			nameCY = feature.NameCY
 		}
 
-		station := gauge.Station{
-			DataURL:   "rloi://" + rtoi,
-			AliasURL:  "rloi://" + rtoi,
-			HumanURL:  "https://rloi.naturalresources.wales/ViewDetails?station=" + rtoi,
-			Name:      feature.Title,
-			RiverName: "", // not available
-			Lat:       feature.Coords.Lat,
-			Lg:        feature.Coords.Lng,
-			Type:      stationType,
-			Unit:      parameters.Units,
+		// only one river level per station can take the plain RLOI alias
+		// used in calibrations, so it goes to the lowest parameter ID
+		primaryLevel := -1
+		for _, parameters := range feature.Parameters {
+			if parameterType(parameters.Name) != "level" {
+				continue
+			}
+			if primaryLevel < 0 || parameters.ID < primaryLevel {
+				primaryLevel = parameters.ID
+			}
 		}
 
-		reading := gauge.Reading{
-			EventTime: parameters.LatestTime.Time,
-			Value:     parameters.LatestValue,
+		for _, parameters := range feature.Parameters {
+			stationType := parameterType(parameters.Name)
+			if stationType == "" {
+				continue
+			}
+
+			// each parameter is a distinct measure aliased by its data
+			// URL, apart from the primary river level
+			dataURL := "rloi://" + rtoi + "/" + strconv.Itoa(parameters.ID)
+			aliasURL := dataURL
+			if stationType == "level" && parameters.ID == primaryLevel {
+				aliasURL = "rloi://" + rtoi
+			}
+
+			station := gauge.Station{
+				DataURL:   dataURL,
+				AliasURL:  aliasURL,
+				HumanURL:  "https://rloi.naturalresources.wales/ViewDetails?station=" + rtoi,
+				Name:      feature.Title,
+				NameCY:    nameCY,
+				RiverName: "", // not available
+				Lat:       feature.Coords.Lat,
+				Lg:        feature.Coords.Lng,
+				Type:      stationType,
+				Unit:      parameters.Units,
+			}
+
+			reading := gauge.Reading{
+				EventTime: parameters.LatestTime.Time,
+				Value:     parameters.LatestValue,
+				Quality:   gauge.QualityProvisional,
+				Source:    recentSource,
+			}
+
+			snaps = append(snaps, gauge.Snapshot{
+				Station:  station,
+				Readings: []gauge.Reading{reading},
+			})
 		}
-
-		snaps = append(snaps, gauge.Snapshot{
-			Station:  station,
-			Readings: []gauge.Reading{reading},
-		})
 	}
 
 	return snaps, nil
 }
+
+// parameterType maps an NRW parameter name such as "River Level" or
+// "Tide Level" to a measure type, blank if it is not a supported type
+func parameterType(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "rain"):
+		return "rainfall"
+	case strings.Contains(name, "tid"):
+		return "tidal"
+	case strings.Contains(name, "flow"):
+		return "flow"
+	case strings.Contains(name, "temp"):
+		return "temperature"
+	case strings.Contains(name, "level"):
+		return "level"
+	}
+	return ""
+}
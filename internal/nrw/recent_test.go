@@ -0,0 +1,113 @@
+package nrw
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecentParse(t *testing.T) {
+	b := bytes.NewBufferString(jsonResponseFromAPI)
+	snaps, err := parseRecent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snaps) == 0 {
+		t.Fatal("No snaps parsed")
+	}
+
+	for _, snap := range snaps {
+		s := snap.Station
+
+		if s.DataURL == "rloi://0" {
+			t.Fatal("zero RTOI detected", s)
+		}
+		if len(s.Name) < 3 {
+			t.Fatal("Missing name detected ", s)
+		}
+		if len(s.Unit) < 1 {
+			t.Fatal("Missing unit detected ", s)
+		}
+
+		if len(snap.Readings) != 1 {
+			t.Fatal("Missing reading ", snap)
+		}
+	}
+}
+
+func TestRecentParseMultiParameter(t *testing.T) {
+	b := bytes.NewBufferString(`[{
+		"coordinates":{"latitude":52.1,"longitude":-3.4},
+		"location":4155,
+		"nameEN":"Builth Wells",
+		"nameCY":"Llanfair-ym-Muallt",
+		"titleEn":"Builth Wells river gauge",
+		"titleCy":"Mesurydd afon Llanfair-ym-Muallt",
+		"parameters":[
+			{"parameter":10320,"paramNameEN":"River Level","units":"m","latestValue":0.91,"latestTime":"2020-10-06T20:00:00Z"},
+			{"parameter":10321,"paramNameEN":"Flow","units":"m3/s","latestValue":32.5,"latestTime":"2020-10-06T20:00:00Z"},
+			{"parameter":10322,"paramNameEN":"Wind Speed","units":"m/s","latestValue":4,"latestTime":"2020-10-06T20:00:00Z"}
+		]
+	}]`)
+	snaps, err := parseRecent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Fatal("expected level and flow snaps, got", snaps)
+	}
+
+	level, flow := snaps[0].Station, snaps[1].Station
+	if level.Type != "level" || level.DataURL != "rloi://4155/10320" || level.AliasURL != "rloi://4155" {
+		t.Error("unexpected level station", level)
+	}
+	if flow.Type != "flow" || flow.DataURL != "rloi://4155/10321" || flow.AliasURL != flow.DataURL {
+		t.Error("unexpected flow station", flow)
+	}
+	if level.NameCY != "Mesurydd afon Llanfair-ym-Muallt" || flow.NameCY != level.NameCY {
+		t.Error("Welsh name not carried through", level.NameCY, flow.NameCY)
+	}
+}
+
+func TestRecentParseSecondLevelHasOwnAlias(t *testing.T) {
+	b := bytes.NewBufferString(`[{
+		"coordinates":{"latitude":52.1,"longitude":-3.4},
+		"location":4155,
+		"titleEn":"Builth Wells river gauge",
+		"parameters":[
+			{"parameter":10324,"paramNameEN":"Stilling Well Level","units":"m","latestValue":0.92,"latestTime":"2020-10-06T20:00:00Z"},
+			{"parameter":10320,"paramNameEN":"River Level","units":"m","latestValue":0.91,"latestTime":"2020-10-06T20:00:00Z"}
+		]
+	}]`)
+	snaps, err := parseRecent(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Fatal("expected 2 level snaps, got", snaps)
+	}
+
+	second, primary := snaps[0].Station, snaps[1].Station
+	if primary.DataURL != "rloi://4155/10320" || primary.AliasURL != "rloi://4155" {
+		t.Error("unexpected primary level station", primary)
+	}
+	if second.DataURL != "rloi://4155/10324" || second.AliasURL != second.DataURL {
+		t.Error("unexpected second level station", second)
+	}
+}
+
+func TestParameterType(t *testing.T) {
+	expected := map[string]string{
+		"River Level":       "level",
+		"Rainfall":          "rainfall",
+		"Tide Level":        "tidal",
+		"Flow":              "flow",
+		"Water Temperature": "temperature",
+		"Wind Speed":        "",
+	}
+	for name, typ := range expected {
+		if actual := parameterType(name); actual != typ {
+			t.Error(name, "expected", typ, "got", actual)
+		}
+	}
+}
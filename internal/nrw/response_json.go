@@ -1,4 +1,4 @@
-package main
+package nrw
 
 // curl -v -X GET "https://api.naturalresources.wales/rivers-and-seas/v1/api/StationData"
 //      -H "Ocp-Apim-Subscription-Key: {subscription key}"
@@ -0,0 +1,197 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redeliveryDelay is the pause before a rejected message is retried
+const redeliveryDelay = 100 * time.Millisecond
+
+var (
+	sharedMutex  sync.Mutex
+	sharedMemory = make(map[string]*Memory)
+)
+
+// Memory is an in-process broker
+//
+// Every consumer group receives a copy of each message published once the
+// group exists, and subscribers within the same group share its messages.
+// Messages published with no consumer groups are discarded.
+type Memory struct {
+	mu     sync.Mutex
+	groups map[string]*memoryGroup
+	nextID int
+}
+
+type memoryGroup struct {
+	mu         sync.Mutex
+	pending    []*Message
+	delivering int
+	readyC     chan struct{}
+}
+
+// NewMemory creates a private in-process broker
+func NewMemory() *Memory {
+	return &Memory{
+		groups: make(map[string]*memoryGroup),
+	}
+}
+
+// SharedMemory provides the in-process broker registered under name,
+// creating it if necessary
+func SharedMemory(name string) *Memory {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	m, ok := sharedMemory[name]
+	if !ok {
+		m = NewMemory()
+		sharedMemory[name] = m
+	}
+	return m
+}
+
+// CreateGroup registers a durable consumer group so that messages are
+// retained for it before any subscriber connects
+func (b *Memory) CreateGroup(consumerGroup string) {
+	b.join(consumerGroup)
+}
+
+// Idle reports whether every message has been delivered and processed
+func (b *Memory) Idle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, g := range b.groups {
+		g.mu.Lock()
+		isBusy := len(g.pending) > 0 || g.delivering > 0
+		g.mu.Unlock()
+		if isBusy {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop is a no-op as the broker may be shared with other topics
+func (b *Memory) Stop() {}
+
+// Publish queues the message for every consumer group
+func (b *Memory) Publish(ctx context.Context, m *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, g := range b.groups {
+		g.push(m)
+	}
+	return nil
+}
+
+// Subscribe delivers messages for the consumer group until ctx is cancelled
+func (b *Memory) Subscribe(ctx context.Context, consumerGroup string,
+	fn func(ctx context.Context, m *Message) error) error {
+	g := b.join(consumerGroup)
+	if consumerGroup == "" {
+		defer b.leave(g)
+	}
+
+	for {
+		m, ok := g.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-g.readyC:
+			}
+			continue
+		}
+
+		err := fn(ctx, m)
+		if err != nil {
+			g.push(m)
+		}
+		g.done()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(redeliveryDelay):
+			}
+		}
+	}
+}
+
+func (b *Memory) join(consumerGroup string) *memoryGroup {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if consumerGroup == "" {
+		b.nextID++
+		consumerGroup = "ephemeral." + strconv.Itoa(b.nextID)
+	}
+	g, ok := b.groups[consumerGroup]
+	if !ok {
+		g = &memoryGroup{
+			readyC: make(chan struct{}, 1),
+		}
+		b.groups[consumerGroup] = g
+	}
+	return g
+}
+
+func (b *Memory) leave(g *memoryGroup) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, candidate := range b.groups {
+		if candidate == g {
+			delete(b.groups, name)
+		}
+	}
+}
+
+func (g *memoryGroup) push(m *Message) {
+	g.mu.Lock()
+	g.pending = append(g.pending, m)
+	g.mu.Unlock()
+
+	// wake a subscriber if none are already due to wake
+	select {
+	case g.readyC <- struct{}{}:
+	default:
+	}
+}
+
+func (g *memoryGroup) pop() (*Message, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.pending) == 0 {
+		return nil, false
+	}
+	m := g.pending[0]
+	g.pending[0] = nil
+	g.pending = g.pending[1:]
+	g.delivering++
+
+	// if more remain, make sure another subscriber is woken
+	if len(g.pending) > 0 {
+		select {
+		case g.readyC <- struct{}{}:
+		default:
+		}
+	}
+	return m, true
+}
+
+func (g *memoryGroup) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.delivering--
+}
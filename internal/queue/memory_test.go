@@ -0,0 +1,262 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestMemoryTopicRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	topic, span := Open(ctx, "mem://test-round-trip")
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer topic.Stop()
+
+	received := make(chan *gauge.Snapshot)
+	done := make(chan error)
+	go func() {
+		done <- topic.Subscribe(ctx, "", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+			if err != nil {
+				t.Error("decode error", err)
+			}
+			received <- s
+			return nil
+		})
+	}()
+
+	// wait for the subscription to be registered before publishing
+	b := SharedMemory("test-round-trip")
+	for !hasGroups(b) {
+		time.Sleep(time.Millisecond)
+	}
+
+	span = topic.Publish(ctx, &gauge.Snapshot{
+		Station: gauge.Station{
+			DataURL:  "http://example.com/data",
+			AliasURL: "rloi://1234",
+			Type:     "level",
+		},
+		Readings:       []gauge.Reading{{EventTime: time.Unix(1600000000, 0), Value: 1.23}},
+		QualityChecked: true,
+	})
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := <-received
+	if s.Station.AliasURL != "rloi://1234" {
+		t.Error("Alias URL mis-match", s.Station)
+	}
+	if !s.QualityChecked {
+		t.Error("Quality checked marker lost", s)
+	}
+	if len(s.Readings) != 1 || s.Readings[0].Value != 1.23 {
+		t.Error("Readings mis-match", s.Readings)
+	}
+	if s.CorrelationID == "" {
+		t.Error("No correlation ID", s)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+	if hasGroups(b) {
+		t.Error("ephemeral consumer group not removed")
+	}
+}
+
+func TestMemoryRedeliversRejectedMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := NewMemory()
+	b.join("durable")
+	if err := b.Publish(ctx, &Message{Data: []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+
+	nAttempts := 0
+	b.Subscribe(ctx, "durable", func(ctx context.Context, m *Message) error {
+		nAttempts++
+		if nAttempts < 3 {
+			return errors.New("not yet")
+		}
+		cancel()
+		return nil
+	})
+
+	if nAttempts != 3 {
+		t.Error("Expected 3 delivery attempts, got", nAttempts)
+	}
+}
+
+func TestMemoryConsumerGroupsEachReceiveMessage(t *testing.T) {
+	ctx := context.Background()
+
+	b := NewMemory()
+	g1 := b.join("one")
+	g2 := b.join("two")
+	if err := b.Publish(ctx, &Message{Data: []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, g := range []*memoryGroup{g1, g2} {
+		m, ok := g.pop()
+		if !ok || string(m.Data) != "a" {
+			t.Error("consumer group missing message", m)
+		}
+	}
+}
+
+func hasGroups(b *Memory) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.groups) > 0
+}
+
+func TestMemoryTopicUnpacksBatches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := NewMemory()
+	b.CreateGroup("test")
+	topic := NewTopic(b)
+
+	span := topic.PublishBatch(ctx, []*gauge.Snapshot{
+		{Station: gauge.Station{AliasURL: "rloi://1", Type: "level"}, QualityChecked: true},
+		{Station: gauge.Station{AliasURL: "rloi://2", Type: "flow"}, QualityChecked: true},
+	})
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var aliases []string
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		if err != nil {
+			t.Error("decode error", err)
+		}
+		if !s.QualityChecked {
+			t.Error("Quality checked marker lost", s)
+		}
+		aliases = append(aliases, s.Station.AliasURL)
+		if len(aliases) == 2 {
+			cancel()
+		}
+		return nil
+	})
+
+	if len(aliases) != 2 || aliases[0] != "rloi://1" || aliases[1] != "rloi://2" {
+		t.Error("unexpected batch snapshots", aliases)
+	}
+}
+
+func TestMemoryTopicCarriesSchemaVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := NewMemory()
+	g := b.join("test")
+	topic := NewTopic(b)
+	if err := topic.Publish(ctx, &gauge.Snapshot{Station: gauge.Station{AliasURL: "rloi://1"}}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := g.pop()
+	if !ok {
+		t.Fatal("no message published")
+	}
+	if m.Attributes[schemaVersionAttribute] != strconv.Itoa(gauge.SchemaVersion) {
+		t.Error("schema version mis-match", m.Attributes)
+	}
+	if m.Attributes[schemaFingerprintAttribute] != strconv.FormatUint(gauge.CurrentSchema.Fingerprint, 16) {
+		t.Error("schema fingerprint mis-match", m.Attributes)
+	}
+
+	// an unknown writer schema is a decode error rather than a redelivery
+	m.Attributes[schemaVersionAttribute] = "99"
+	m.Attributes[schemaFingerprintAttribute] = "1"
+	if err := b.Publish(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		if err == nil {
+			t.Error("expected unknown schema error")
+		}
+		cancel()
+		return nil
+	})
+}
+
+func TestMemoryTopicDecodesUnversionedMessagesAsVersion1(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a message from a publisher that pre-dates schema attributes
+	b := NewMemory()
+	b.CreateGroup("test")
+	v1, err := ioutil.ReadFile("../gauge/testdata/snapshot-v1.avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Publish(ctx, &Message{Data: v1}); err != nil {
+		t.Fatal(err)
+	}
+
+	NewTopic(b).Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		if err != nil {
+			t.Error(err)
+		}
+		if s.Station.Name != "Bourton Dickler" || len(s.Readings) != 2 || s.CorrelationID != "ABCDE" {
+			t.Error("snapshot mis-match", s)
+		}
+		cancel()
+		return nil
+	})
+}
+
+func TestMemoryTopicDeadLettersCorruptMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := NewMemory()
+	b.CreateGroup("test")
+	topic := NewTopic(b)
+	dlBroker := NewMemory()
+	dl := dlBroker.join("inspect")
+	topic.UseDeadLetter(NewTopic(dlBroker))
+
+	corrupt := &Message{
+		Data:       []byte{0xff, 0xff},
+		Attributes: map[string]string{qualityAttribute: qualityChecked},
+	}
+	if err := b.Publish(ctx, corrupt); err != nil {
+		t.Fatal(err)
+	}
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		if err == nil {
+			t.Error("expected decode error")
+		}
+		cancel()
+		return nil
+	})
+
+	m, ok := dl.pop()
+	if !ok {
+		t.Fatal("corrupt message not dead-lettered")
+	}
+	if string(m.Data) != string(corrupt.Data) || m.Attributes[qualityAttribute] != qualityChecked {
+		t.Error("dead-lettered message mis-match", m)
+	}
+	if m.Attributes[errorAttribute] == "" {
+		t.Error("dead-lettered message has no error", m.Attributes)
+	}
+}
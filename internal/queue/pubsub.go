@@ -1,135 +1,83 @@
 package queue
 
 import (
-	"bytes"
 	"context"
 	"time"
 
 	"cloud.google.com/go/pubsub"
-	"github.com/robtuley/rainchasers/internal/gauge"
-	"github.com/robtuley/report"
 )
 
-// Topic encapsulates the message queue topic
-type Topic struct {
-	ProjectID string
-	pubSub    *pubsub.Topic
+// PubSub is a Google Pub/Sub topic broker
+type PubSub struct {
+	client *pubsub.Client
+	topic  *pubsub.Topic
 }
 
-// Stop cleanly closes the topic
-func (t *Topic) Stop() {
-	if t.pubSub != nil {
-		t.pubSub.Stop()
-	}
-}
-
-// New creates a message queue topic
-func New(ctx context.Context, projectID string, topicName string) (*Topic, report.Span) {
+func newPubSub(ctx context.Context, projectID string, topicName string) (*PubSub, error) {
 	ctx, cancel := context.WithTimeout(ctx, 40*time.Second)
 	defer cancel()
 
-	span := report.StartSpan("topic.connected").Field("project_id", projectID).Field("topic_name", topicName)
-
-	if len(projectID) == 0 {
-		return &Topic{}, span.End()
-	}
-
 	client, err := pubsub.NewClient(ctx, projectID)
 	if err != nil {
-		return nil, span.End(err)
+		return nil, err
 	}
 
 	topic := client.Topic(topicName)
 	exists, err := topic.Exists(ctx)
 	if err != nil {
-		return nil, span.End(err)
+		return nil, err
 	}
 	if !exists {
-		cSpan := report.StartSpan("topic.created")
 		topic, err = client.CreateTopic(ctx, topicName)
-		span = span.Child(cSpan.End(err))
 		if err != nil {
-			return nil, span.End()
+			return nil, err
 		}
 	}
 
-	return &Topic{
-		ProjectID: projectID,
-		pubSub:    topic,
-	}, span.End()
+	return &PubSub{
+		client: client,
+		topic:  topic,
+	}, nil
 }
 
-// Publish writes an AVRO encoded Snapshot to the topic
-func (t *Topic) Publish(ctx context.Context, s *gauge.Snapshot) report.Span {
-	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
-	defer cancel()
-
-	span := report.StartSpan("snapshot.published")
-	span = span.Field("station", s.Station.AliasURL)
-	span = span.Field("count_readings", len(s.Readings))
-
-	if s.CorrelationID == "" {
-		// if there is no predefined existing trace info,
-		// assume this span tracer will be the trace generator
-		s.CorrelationID = span.TraceID()
-		s.CausationID = span.SpanID()
-	}
-	s.ProcessedTime = time.Now()
-
-	bb := bytes.NewBuffer([]byte{})
-	err := s.Encode(bb)
-	if err != nil {
-		return span.End(err)
-	}
-
-	if t.pubSub == nil {
-		return span.End()
-	}
+// Stop flushes any pending messages and closes the topic and client
+func (p *PubSub) Stop() {
+	p.topic.Stop()
+	p.client.Close()
+}
 
-	result := t.pubSub.Publish(ctx, &pubsub.Message{
-		Data: bb.Bytes(),
+// Publish sends a message and waits for the server to accept it
+func (p *PubSub) Publish(ctx context.Context, m *Message) error {
+	result := p.topic.Publish(ctx, &pubsub.Message{
+		Data:       m.Data,
+		Attributes: m.Attributes,
 	})
-	_, err = result.Get(ctx)
-
-	return span.End(err)
+	_, err := result.Get(ctx)
+	return err
 }
 
-// Subscribe reads AVRO encoded snapshots from the topic and decodes them
-//
-// Note a zero length consumerGroup means auto-generate the pubsub subscription
-// string and delete once done.
-func (t *Topic) Subscribe(ctx context.Context, consumerGroup string,
-	fn func(ctx context.Context, err error, s *gauge.Snapshot) error) error {
+// Subscribe receives messages from a subscription named after the consumer group
+func (p *PubSub) Subscribe(ctx context.Context, consumerGroup string,
+	fn func(ctx context.Context, m *Message) error) error {
 	const ackDeadline = time.Second * 20
 
-	if t.pubSub == nil {
-		// simulate a subscription i.e. wait for ctx cancel then return
-		<-ctx.Done()
-		return nil
-	}
-
 	isDeleteSubOnComplete := (consumerGroup == "")
 	if isDeleteSubOnComplete {
 		consumerGroup = time.Now().Format("v2006-01-02-15-04-05.999999")
 	}
-	subName := t.pubSub.ID() + "." + consumerGroup
-
-	client, err := pubsub.NewClient(ctx, t.ProjectID)
-	if err != nil {
-		return err
-	}
+	subName := p.topic.ID() + "." + consumerGroup
 
-	sub := client.Subscription(subName)
+	sub := p.client.Subscription(subName)
 	exists, err := sub.Exists(ctx)
 	if err != nil {
 		return err
 	}
 	if !exists {
 		cfg := pubsub.SubscriptionConfig{
-			Topic:       t.pubSub,
+			Topic:       p.topic,
 			AckDeadline: ackDeadline,
 		}
-		sub, err = client.CreateSubscription(ctx, subName, cfg)
+		sub, err = p.client.CreateSubscription(ctx, subName, cfg)
 		if err != nil {
 			return err
 		}
@@ -142,9 +90,10 @@ func (t *Topic) Subscribe(ctx context.Context, consumerGroup string,
 		ctx, cancel := context.WithTimeout(ctx, ackDeadline-time.Second)
 		defer cancel()
 
-		s := gauge.Snapshot{}
-		err := s.Decode(bytes.NewBuffer(m.Data))
-		err = fn(ctx, err, &s)
+		err := fn(ctx, &Message{
+			Data:       m.Data,
+			Attributes: m.Attributes,
+		})
 		if err != nil {
 			m.Nack() // speed up message redelivery
 			return
@@ -0,0 +1,343 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+// qualityAttribute marks a message of quality checked readings
+const (
+	qualityAttribute = "quality"
+	qualityChecked   = "checked"
+)
+
+// encodingAttribute marks a message that is a batch of several snapshots
+const (
+	encodingAttribute = "encoding"
+	encodingBatch     = "batch"
+)
+
+// schema attributes identify the writer schema a message is encoded with
+const (
+	schemaVersionAttribute     = "schema_version"
+	schemaFingerprintAttribute = "schema_fingerprint"
+)
+
+// errorAttribute records why a dead-lettered message could not be decoded
+const errorAttribute = "error"
+
+// Message is an encoded payload as carried by a Broker
+type Message struct {
+	Data       []byte
+	Attributes map[string]string
+}
+
+// Broker is a message queue implementation that transports messages
+//
+// Subscribe delivers each message to fn and should redeliver it later if fn
+// returns an error. A zero length consumerGroup is an ephemeral subscription
+// that only lasts as long as the Subscribe call.
+type Broker interface {
+	Publish(ctx context.Context, m *Message) error
+	Subscribe(ctx context.Context, consumerGroup string, fn func(ctx context.Context, m *Message) error) error
+	Stop()
+}
+
+// Topic encapsulates the message queue topic
+type Topic struct {
+	broker     Broker
+	schemas    *gauge.Registry
+	deadLetter *Topic
+	concurrent bool
+}
+
+// NewTopic creates a message queue topic on top of an existing broker
+func NewTopic(b Broker) *Topic {
+	return &Topic{broker: b, schemas: gauge.NewRegistry()}
+}
+
+// UseSchemas sets the registry of writer schemas used to decode messages
+// published with other schema versions during a rolling deploy
+func (t *Topic) UseSchemas(r *gauge.Registry) {
+	t.schemas = r
+}
+
+// Stop cleanly closes the topic
+func (t *Topic) Stop() {
+	t.broker.Stop()
+}
+
+// UseDeadLetter sets a topic that messages which cannot be decoded are
+// published to, rather than being dropped
+func (t *Topic) UseDeadLetter(dl *Topic) {
+	t.deadLetter = dl
+}
+
+// UseConcurrentBatches passes the snapshots of a batch message to the
+// subscriber concurrently, so one that is slow to handle does not hold up
+// the rest of the batch
+func (t *Topic) UseConcurrentBatches() {
+	t.concurrent = true
+}
+
+// New creates a Google Pub/Sub message queue topic
+//
+// A zero length projectID is a dry run, where snapshots are encoded but
+// then discarded.
+func New(ctx context.Context, projectID string, topicName string) (*Topic, report.Span) {
+	span := report.StartSpan("topic.connected").Field("project_id", projectID).Field("topic_name", topicName)
+
+	if len(projectID) == 0 {
+		return NewTopic(NewMemory()), span.End()
+	}
+
+	b, err := newPubSub(ctx, projectID, topicName)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	return NewTopic(b), span.End()
+}
+
+// Open creates a message queue topic from a URL
+//
+// Supported URLs are:
+//   pubsub://<project>/<topic> for Google Pub/Sub
+//   mem://<name> for an in-process broker shared by name
+//   redis://<host>:<port>/<stream> for a Redis stream
+func Open(ctx context.Context, rawURL string) (*Topic, report.Span) {
+	span := report.StartSpan("topic.connected").Field("url", rawURL)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+
+	var b Broker
+	switch u.Scheme {
+	case "pubsub":
+		b, err = newPubSub(ctx, u.Host, name)
+	case "mem":
+		b = SharedMemory(u.Host + u.Path)
+	case "redis":
+		b, err = newRedis(ctx, u.Host, name)
+	default:
+		err = errors.New("unsupported queue URL scheme " + u.Scheme)
+	}
+	if err != nil {
+		return nil, span.End(err)
+	}
+	return NewTopic(b), span.End()
+}
+
+// Connect opens the topic at queueURL, or the Pub/Sub topic if queueURL is blank
+func Connect(ctx context.Context, queueURL string, projectID string, topicName string) (*Topic, report.Span) {
+	if len(queueURL) > 0 {
+		return Open(ctx, queueURL)
+	}
+	return New(ctx, projectID, topicName)
+}
+
+// Publish writes an AVRO encoded Snapshot to the topic
+func (t *Topic) Publish(ctx context.Context, s *gauge.Snapshot) report.Span {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	span := report.StartSpan("snapshot.published")
+	span = span.Field("station", s.Station.AliasURL)
+	span = span.Field("count_readings", len(s.Readings))
+
+	if s.CorrelationID == "" {
+		// if there is no predefined existing trace info,
+		// assume this span tracer will be the trace generator
+		s.CorrelationID = span.TraceID()
+		s.CausationID = span.SpanID()
+	}
+	s.ProcessedTime = time.Now()
+
+	bb := bytes.NewBuffer([]byte{})
+	err := s.Encode(bb)
+	if err != nil {
+		return span.End(err)
+	}
+
+	m := &Message{
+		Data:       bb.Bytes(),
+		Attributes: schemaAttributes(),
+	}
+	if s.QualityChecked {
+		m.Attributes[qualityAttribute] = qualityChecked
+		span = span.Field("quality_checked", true)
+	}
+	err = t.broker.Publish(ctx, m)
+	return span.End(err)
+}
+
+// PublishBatch writes several AVRO encoded Snapshots as a single message
+//
+// The batch is only marked as quality checked if every snapshot is.
+func (t *Topic) PublishBatch(ctx context.Context, snapshots []*gauge.Snapshot) report.Span {
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+	defer cancel()
+
+	span := report.StartSpan("batch.published")
+	span = span.Field("count_snapshots", len(snapshots))
+	if len(snapshots) == 0 {
+		return span.End()
+	}
+
+	isQualityChecked := true
+	nReadings := 0
+	for _, s := range snapshots {
+		if s.CorrelationID == "" {
+			s.CorrelationID = span.TraceID()
+			s.CausationID = span.SpanID()
+		}
+		s.ProcessedTime = time.Now()
+		isQualityChecked = isQualityChecked && s.QualityChecked
+		nReadings += len(s.Readings)
+	}
+	span = span.Field("count_readings", nReadings)
+
+	bb := bytes.NewBuffer([]byte{})
+	err := gauge.EncodeBatch(bb, snapshots)
+	if err != nil {
+		return span.End(err)
+	}
+
+	m := &Message{
+		Data:       bb.Bytes(),
+		Attributes: schemaAttributes(),
+	}
+	m.Attributes[encodingAttribute] = encodingBatch
+	if isQualityChecked {
+		m.Attributes[qualityAttribute] = qualityChecked
+		span = span.Field("quality_checked", true)
+	}
+	err = t.broker.Publish(ctx, m)
+	return span.End(err)
+}
+
+func schemaAttributes() map[string]string {
+	return map[string]string{
+		schemaVersionAttribute:     strconv.Itoa(gauge.SchemaVersion),
+		schemaFingerprintAttribute: strconv.FormatUint(gauge.CurrentSchema.Fingerprint, 16),
+	}
+}
+
+// writerSchema finds the schema a message was encoded with
+//
+// A message without schema attributes pre-dates versioning so was written
+// with version 1.
+func (t *Topic) writerSchema(m *Message) (*gauge.Schema, error) {
+	v, ok := m.Attributes[schemaVersionAttribute]
+	if !ok {
+		return t.schemas.Lookup(1, 0)
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, errors.New("invalid schema version " + v)
+	}
+	var fingerprint uint64
+	if f, ok := m.Attributes[schemaFingerprintAttribute]; ok {
+		fingerprint, err = strconv.ParseUint(f, 16, 64)
+		if err != nil {
+			return nil, errors.New("invalid schema fingerprint " + f)
+		}
+	}
+	return t.schemas.Lookup(version, fingerprint)
+}
+
+// corrupted passes a decode error to fn, once the message is safely on any
+// dead-letter topic (with the error as an attribute)
+func (t *Topic) corrupted(ctx context.Context, m *Message, err error,
+	fn func(ctx context.Context, err error, s *gauge.Snapshot) error) error {
+	if t.deadLetter != nil {
+		dl := &Message{
+			Data:       m.Data,
+			Attributes: map[string]string{errorAttribute: err.Error()},
+		}
+		for k, v := range m.Attributes {
+			dl.Attributes[k] = v
+		}
+		if err := t.deadLetter.broker.Publish(ctx, dl); err != nil {
+			return err
+		}
+	}
+	return fn(ctx, err, &gauge.Snapshot{})
+}
+
+// Subscribe reads AVRO encoded snapshots from the topic and decodes them
+//
+// Messages are decoded with the writer schema named in their attributes, so
+// older and newer snapshot versions are read side by side. A message that
+// cannot be decoded is sent to the dead-letter topic if set.
+//
+// A batch message is unpacked so fn sees each of its snapshots in turn (or
+// all at once after UseConcurrentBatches), and the whole batch is
+// redelivered if fn returns an error for any of them.
+// Note a zero length consumerGroup means an ephemeral subscription that is
+// deleted once done.
+func (t *Topic) Subscribe(ctx context.Context, consumerGroup string,
+	fn func(ctx context.Context, err error, s *gauge.Snapshot) error) error {
+	return t.broker.Subscribe(ctx, consumerGroup, func(ctx context.Context, m *Message) error {
+		isQualityChecked := m.Attributes[qualityAttribute] == qualityChecked
+
+		writer, err := t.writerSchema(m)
+		if err != nil {
+			return t.corrupted(ctx, m, err, fn)
+		}
+
+		if m.Attributes[encodingAttribute] == encodingBatch {
+			snapshots, err := gauge.DecodeBatchWith(bytes.NewBuffer(m.Data), writer)
+			if err != nil {
+				return t.corrupted(ctx, m, err, fn)
+			}
+			var (
+				errs []string
+				mu   sync.Mutex
+				wg   sync.WaitGroup
+			)
+			handle := func(s *gauge.Snapshot) {
+				if err := fn(ctx, nil, s); err != nil {
+					mu.Lock()
+					errs = append(errs, err.Error())
+					mu.Unlock()
+				}
+			}
+			for _, s := range snapshots {
+				s.QualityChecked = isQualityChecked
+				if !t.concurrent {
+					handle(s)
+					continue
+				}
+				wg.Add(1)
+				go func(s *gauge.Snapshot) {
+					defer wg.Done()
+					handle(s)
+				}(s)
+			}
+			wg.Wait()
+			if len(errs) > 0 {
+				return errors.New(strings.Join(errs, "; "))
+			}
+			return nil
+		}
+
+		s := gauge.Snapshot{}
+		if err := s.DecodeWith(bytes.NewBuffer(m.Data), writer); err != nil {
+			return t.corrupted(ctx, m, err, fn)
+		}
+		s.QualityChecked = isQualityChecked
+		return fn(ctx, nil, &s)
+	})
+}
@@ -0,0 +1,412 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redisBlockFor     = 2 * time.Second
+	redisReadCount    = 100
+	redisMaxStreamLen = 100000
+	redisDataField    = "data"
+	redisAttrPrefix   = "attr."
+	redisClaimIdle    = 60 * time.Second
+)
+
+// Redis is a Redis Streams broker
+//
+// Consumer groups map to Redis stream consumer groups, and a rejected
+// message is left pending to be re-read by the same consumer or, once
+// idle, claimed by another.
+type Redis struct {
+	addr   string
+	stream string
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+func newRedis(ctx context.Context, addr string, stream string) (*Redis, error) {
+	if stream == "" {
+		return nil, errors.New("redis queue URL requires a stream name")
+	}
+	conn, err := dialRedis(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{
+		addr:   addr,
+		stream: stream,
+		conn:   conn,
+	}, nil
+}
+
+// Stop closes the publishing connection
+func (r *Redis) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// Publish appends the message to the stream
+func (r *Redis) Publish(ctx context.Context, m *Message) error {
+	args := []string{"XADD", r.stream, "MAXLEN", "~", strconv.Itoa(redisMaxStreamLen), "*",
+		redisDataField, string(m.Data)}
+	for k, v := range m.Attributes {
+		args = append(args, redisAttrPrefix+k, v)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		conn, err := dialRedis(ctx, r.addr)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+	_, err := r.conn.Do(ctx, args...)
+	if isRedisConnError(err) {
+		// the connection is left in an unknown state, so re-dial on
+		// the next publish rather than failing every later one
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// Subscribe reads the stream as part of a consumer group
+//
+// The consumer is named after the host so a restarted process picks up its
+// own pending entries, and entries left pending by any other consumer for
+// longer than redisClaimIdle are claimed and redelivered here.
+func (r *Redis) Subscribe(ctx context.Context, consumerGroup string,
+	fn func(ctx context.Context, m *Message) error) error {
+	conn, err := dialRedis(ctx, r.addr)
+	if err != nil {
+		return err
+	}
+	defer func() { conn.Close() }()
+
+	isDeleteGroupOnComplete := (consumerGroup == "")
+	if isDeleteGroupOnComplete {
+		consumerGroup = time.Now().Format("v2006-01-02-15-04-05.999999")
+	}
+	consumer := redisConsumerName(consumerGroup)
+
+	_, err = conn.Do(ctx, "XGROUP", "CREATE", r.stream, consumerGroup, "$", "MKSTREAM")
+	if err != nil && !isRedisError(err, "BUSYGROUP") {
+		return err
+	}
+	if isDeleteGroupOnComplete {
+		defer func() {
+			conn.Do(context.Background(), "XGROUP", "DESTROY", r.stream, consumerGroup)
+		}()
+	}
+
+	// pending is the ID after which entries delivered to this consumer but
+	// not yet acknowledged are re-read a page at a time, or blank once they
+	// are drained and new entries are read from ">"
+	pending := "0"
+	var claimedAt time.Time
+
+	// the consumer group and its pending entries live on the server, so
+	// after a dropped connection the pending entries are re-read from "0"
+	redial := func() error {
+		conn.Close()
+		c, err := dialRedis(ctx, r.addr)
+		if err != nil {
+			return err
+		}
+		conn = c
+		pending = "0"
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		var reply interface{}
+		if time.Since(claimedAt) > redisClaimIdle {
+			err = claimIdleRedis(ctx, conn, r.stream, consumerGroup, consumer)
+			if err == nil {
+				claimedAt = time.Now()
+				pending = "0"
+				continue
+			}
+		} else if pending != "" {
+			reply, err = conn.Do(ctx, "XREADGROUP", "GROUP", consumerGroup, consumer,
+				"COUNT", strconv.Itoa(redisReadCount),
+				"STREAMS", r.stream, pending)
+		} else {
+			reply, err = conn.Do(ctx, "XREADGROUP", "GROUP", consumerGroup, consumer,
+				"COUNT", strconv.Itoa(redisReadCount),
+				"BLOCK", strconv.Itoa(int(redisBlockFor/time.Millisecond)),
+				"STREAMS", r.stream, ">")
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !isRedisConnError(err) {
+				return err
+			}
+			if err := redial(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		entries := redisStreamEntries(reply)
+		if pending != "" {
+			if len(entries) == 0 {
+				pending = ""
+			} else {
+				pending = entries[len(entries)-1].ID
+			}
+		}
+
+		isRetryPending := false
+		for _, e := range entries {
+			if err := fn(ctx, e.Message); err != nil {
+				isRetryPending = true
+				continue
+			}
+			if _, err := conn.Do(ctx, "XACK", r.stream, consumerGroup, e.ID); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if !isRedisConnError(err) {
+					return err
+				}
+				if err := redial(); err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		if isRetryPending {
+			pending = "0"
+			select {
+			case <-ctx.Done():
+			case <-time.After(redeliveryDelay):
+			}
+		}
+	}
+
+	return nil
+}
+
+// redisConsumerName is stable across restarts of the same host, which in
+// Kubernetes is the pod name
+func redisConsumerName(consumerGroup string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return consumerGroup
+	}
+	return consumerGroup + "." + host
+}
+
+// claimIdleRedis moves entries that have been pending on any consumer for
+// longer than redisClaimIdle to this consumer, paging through the whole
+// pending list
+func claimIdleRedis(ctx context.Context, conn *redisConn, stream string,
+	consumerGroup string, consumer string) error {
+	idle := strconv.Itoa(int(redisClaimIdle / time.Millisecond))
+	cursor := "0-0"
+	for {
+		reply, err := conn.Do(ctx, "XAUTOCLAIM", stream, consumerGroup, consumer,
+			idle, cursor, "COUNT", strconv.Itoa(redisReadCount), "JUSTID")
+		if err != nil {
+			return err
+		}
+		cursor = redisClaimCursor(reply)
+		if cursor == "" || cursor == "0-0" {
+			return nil
+		}
+	}
+}
+
+// redisClaimCursor is the next start ID from a XAUTOCLAIM reply of the form
+// [cursor, [id, ...], ...]
+func redisClaimCursor(reply interface{}) string {
+	items, _ := reply.([]interface{})
+	if len(items) == 0 {
+		return ""
+	}
+	cursor, _ := items[0].(string)
+	return cursor
+}
+
+type redisEntry struct {
+	ID      string
+	Message *Message
+}
+
+// redisStreamEntries unpacks a XREADGROUP reply of the form
+// [[stream, [[id, [field, value, ...]], ...]]]
+func redisStreamEntries(reply interface{}) []redisEntry {
+	var entries []redisEntry
+
+	streams, _ := reply.([]interface{})
+	for _, s := range streams {
+		stream, _ := s.([]interface{})
+		if len(stream) != 2 {
+			continue
+		}
+		items, _ := stream[1].([]interface{})
+		for _, i := range items {
+			item, _ := i.([]interface{})
+			if len(item) != 2 {
+				continue
+			}
+			id, _ := item[0].(string)
+			fields, _ := item[1].([]interface{})
+
+			m := &Message{}
+			for j := 0; j+1 < len(fields); j += 2 {
+				k, _ := fields[j].(string)
+				v, _ := fields[j+1].(string)
+				switch {
+				case k == redisDataField:
+					m.Data = []byte(v)
+				case strings.HasPrefix(k, redisAttrPrefix):
+					if m.Attributes == nil {
+						m.Attributes = make(map[string]string)
+					}
+					m.Attributes[strings.TrimPrefix(k, redisAttrPrefix)] = v
+				}
+			}
+			entries = append(entries, redisEntry{ID: id, Message: m})
+		}
+	}
+
+	return entries
+}
+
+// redisConn is a minimal RESP protocol client connection
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// isRedisConnError is true for a network or protocol failure rather than
+// an error reply, after which the connection can no longer be used
+func isRedisConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(redisError)
+	return !ok
+}
+
+func isRedisError(err error, prefix string) bool {
+	re, ok := err.(redisError)
+	return ok && strings.HasPrefix(string(re), prefix)
+}
+
+func dialRedis(ctx context.Context, addr string) (*redisConn, error) {
+	var dialer net.Dialer
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &redisConn{
+		Conn: c,
+		r:    bufio.NewReader(c),
+	}, nil
+}
+
+// Do sends a command and waits for the reply
+func (c *redisConn) Do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(redisBlockFor + 10*time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.Write(encodeRESP(args)); err != nil {
+		return nil, err
+	}
+	return decodeRESP(c.r)
+}
+
+func encodeRESP(args []string) []byte {
+	b := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b = append(b, "$"+strconv.Itoa(len(a))+"\r\n"...)
+		b = append(b, a...)
+		b = append(b, "\r\n"...)
+	}
+	return b
+}
+
+func decodeRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed redis reply " + strconv.Quote(line))
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i], err = decodeRESP(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+
+	return nil, errors.New("unknown redis reply type " + strconv.Quote(string(kind)))
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRESP(t *testing.T) {
+	b := encodeRESP([]string{"XACK", "gauge", ""})
+	expected := "*3\r\n$4\r\nXACK\r\n$5\r\ngauge\r\n$0\r\n\r\n"
+	if string(b) != expected {
+		t.Errorf("unexpected encoding %q", b)
+	}
+}
+
+func TestDecodeRESP(t *testing.T) {
+	raw := "*1\r\n*2\r\n$5\r\ngauge\r\n*1\r\n*2\r\n$3\r\n1-0\r\n" +
+		"*4\r\n$4\r\ndata\r\n$4\r\na\r\nb\r\n$8\r\nattr.key\r\n$5\r\nvalue\r\n"
+	reply, err := decodeRESP(bufio.NewReader(bytes.NewBufferString(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := redisStreamEntries(reply)
+	if len(entries) != 1 {
+		t.Fatal("expected 1 entry, got", len(entries))
+	}
+	if entries[0].ID != "1-0" {
+		t.Error("ID mis-match", entries[0].ID)
+	}
+	if string(entries[0].Message.Data) != "a\r\nb" {
+		t.Errorf("Data mis-match %q", entries[0].Message.Data)
+	}
+	if !reflect.DeepEqual(entries[0].Message.Attributes, map[string]string{"key": "value"}) {
+		t.Error("Attributes mis-match", entries[0].Message.Attributes)
+	}
+}
+
+func TestDecodeRESPErrorsAndNils(t *testing.T) {
+	_, err := decodeRESP(bufio.NewReader(bytes.NewBufferString("-BUSYGROUP exists\r\n")))
+	if !isRedisError(err, "BUSYGROUP") {
+		t.Error("expected BUSYGROUP error, got", err)
+	}
+
+	reply, err := decodeRESP(bufio.NewReader(bytes.NewBufferString("*-1\r\n")))
+	if err != nil || reply != nil {
+		t.Error("expected nil reply", reply, err)
+	}
+	if entries := redisStreamEntries(reply); len(entries) != 0 {
+		t.Error("expected no entries from nil reply", entries)
+	}
+}
+
+func TestRedisPublishRedialsAfterDroppedConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the first connection is dropped, later ones reply to each command
+	go func() {
+		for i := 0; ; i++ {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				c.Close()
+				continue
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				for {
+					if _, err := decodeRESP(r); err != nil {
+						return
+					}
+					c.Write([]byte("$3\r\n1-0\r\n"))
+				}
+			}()
+		}
+	}()
+
+	ctx := context.Background()
+	r, err := newRedis(ctx, l.Addr().String(), "gauge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if err := r.Publish(ctx, &Message{Data: []byte("a")}); err == nil {
+		t.Fatal("expected error from dropped connection")
+	}
+	if err := r.Publish(ctx, &Message{Data: []byte("b")}); err != nil {
+		t.Error("expected publish to re-dial, got", err)
+	}
+}
+
+func TestRedisConsumerNameIsStable(t *testing.T) {
+	if redisConsumerName("store") != redisConsumerName("store") {
+		t.Error("consumer name changes between subscriptions")
+	}
+}
+
+func TestRedisClaimCursor(t *testing.T) {
+	raw := "*3\r\n$3\r\n5-0\r\n*1\r\n$3\r\n1-0\r\n*0\r\n"
+	reply, err := decodeRESP(bufio.NewReader(bytes.NewBufferString(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cursor := redisClaimCursor(reply); cursor != "5-0" {
+		t.Error("cursor mis-match", cursor)
+	}
+}
@@ -1,9 +1,27 @@
 package river
 
+// Strategy is how the level of a section is resolved from several gauges
+type Strategy string
+
+// The default Priority strategy uses the first gauge with a known level, in
+// order of Calibration.Priority then the order the gauges are listed.
+const (
+	Priority Strategy = "priority" // first known level by priority
+	Freshest Strategy = "freshest" // most recent reading
+	Max      Strategy = "max"      // highest level
+	Min      Strategy = "min"      // lowest level
+	Weighted Strategy = "weighted" // weighted mean of the levels
+)
+
+// Strategies are all the valid strategies
+var Strategies = []Strategy{Priority, Freshest, Max, Min, Weighted}
+
 // Calibration is a referenced gauge related to a section
 type Calibration struct {
-	URL         string `firestore:"data_url"`
-	Description string `firestore:"desc"`
+	URL         string  `firestore:"data_url"`
+	Description string  `firestore:"desc"`
+	Priority    int     `firestore:"priority,omitempty"` // lowest first, for priority strategy
+	Weight      float32 `firestore:"weight,omitempty"`   // zero is 1, for weighted strategy
 
 	// Minimum is a map of the minimum values for each level
 	//
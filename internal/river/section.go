@@ -25,4 +25,7 @@ type Section struct {
 	Takeout     LatLng  `firestore:"takeout" yaml:"takeout"`
 	Description string  `firestore:"desc" yaml:"desc"`
 	Directions  string  `firestore:"directions" yaml:"directions"`
+
+	// Strategy resolves the level of a section with several gauges
+	Strategy Strategy `firestore:"level_strategy,omitempty" yaml:"level_strategy,omitempty"`
 }
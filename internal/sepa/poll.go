@@ -0,0 +1,214 @@
+package sepa
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/dedupe"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/report"
+)
+
+// Poller publishes SEPA level and rainfall station readings to the queue on
+// a refresh schedule
+//
+// Stations are fetched in parallel within an overall request rate limit,
+// and a CSV that is unchanged since the last poll is skipped. Priority
+// stations (e.g. those calibrated for a section) are polled first, and the
+// rest only on the slower background refresh period.
+type Poller struct {
+	ProjectID                        string
+	TopicName                        string
+	QueueURL                         string
+	RefreshPeriodInSeconds           int
+	PriorityURLs                     []string // data, alias or human URLs
+	BackgroundRefreshPeriodInSeconds int      // zero is the refresh period
+	Concurrency                      int      // zero is 4
+	RequestsPerSecond                float64  // zero is 1, the SEPA rate limit
+	StatePath                        string   // blank keeps the dedupe state in memory only
+	ExitAfterXConsecutiveErr         int
+	Cycles                           int // zero polls until shutdown
+}
+
+type pollResult struct {
+	index   int
+	started time.Time
+	span    report.Span
+}
+
+// Run polls and publishes readings until shutdown or the cycles are complete
+func (cfg Poller) Run(ctx context.Context, d *daemon.Supervisor) error {
+	// discover SEPA gauging stations
+	stations, dSpan := discover(ctx)
+	if err := dSpan.Err(); err != nil {
+		d.Trace(dSpan)
+		return err
+	}
+	// rainfall is polled alongside levels, but is not worth losing the
+	// levels for if the rainfall stations cannot be discovered
+	rainfall, rSpan := discoverRainfall(ctx)
+	dSpan = dSpan.FollowedBy(rSpan)
+	if rSpan.Err() == nil {
+		stations = append(stations, rainfall...)
+	}
+
+	// open connection to pubsub
+	topic, qSpan := queue.Connect(ctx, cfg.QueueURL, cfg.ProjectID, cfg.TopicName)
+	d.Trace(dSpan.FollowedBy(qSpan))
+	if err := qSpan.Err(); err != nil {
+		return err
+	}
+	defer topic.Stop()
+
+	// only publish readings newer than those already published
+	seen, sSpan := dedupe.New(cfg.StatePath)
+	d.Trace(sSpan)
+	if err := sSpan.Err(); err != nil {
+		return err
+	}
+	defer func() { d.Trace(seen.Save()) }()
+
+	every := time.Duration(cfg.RefreshPeriodInSeconds) * time.Second
+	backgroundEvery := every
+	if cfg.BackgroundRefreshPeriodInSeconds > 0 {
+		backgroundEvery = time.Duration(cfg.BackgroundRefreshPeriodInSeconds) * time.Second
+	}
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = 4
+	}
+	sched := newSchedule(stations, gauge.NewPriority(cfg.PriorityURLs), cfg.Cycles, time.Now())
+	bucket := newTokenBucket(cfg.RequestsPerSecond, 1)
+	validators := &validatorCache{}
+	d.Info("sepa.scheduled", report.Data{
+		"stations_count": len(stations),
+		"priority_count": sched.priorityCount(),
+		"concurrency":    concurrency,
+	})
+
+	// workers fetch & publish, with a result buffer so none block on
+	// exit with a poll in flight
+	ctx, cancel := context.WithCancel(ctx)
+	jobs := make(chan int)
+	results := make(chan pollResult, concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				started := time.Now()
+				span := poll(ctx, validators, seen, topic, stations[i])
+				results <- pollResult{index: i, started: started, span: span}
+			}
+		}()
+	}
+	defer func() {
+		cancel()
+		close(jobs)
+		wg.Wait()
+	}()
+
+	// dispatch stations as they are due
+	nConsecutiveErr := 0
+	handle := func(r pollResult) error {
+		sched.done(r.index, r.started, every, backgroundEvery)
+		d.Trace(r.span)
+		if err := r.span.Err(); err != nil {
+			nConsecutiveErr++
+			if nConsecutiveErr >= cfg.ExitAfterXConsecutiveErr {
+				// ignore a few isolated errors, but if
+				// many consecutive bubble up to restart
+				return err
+			}
+		} else {
+			nConsecutiveErr = 0
+		}
+		return nil
+	}
+	for {
+		// record any finished polls before choosing the next
+		for isDrained := false; !isDrained; {
+			select {
+			case r := <-results:
+				if err := handle(r); err != nil {
+					return err
+				}
+			default:
+				isDrained = true
+			}
+		}
+		if sched.isComplete() {
+			return nil
+		}
+
+		i, wait := sched.next(time.Now(), concurrency)
+		if i >= 0 {
+			if !bucket.wait(ctx) {
+				return nil
+			}
+			sched.start(i)
+			jobs <- i
+			continue
+		}
+
+		// a negative wait means only an in-flight poll can free a station
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case r := <-results:
+			if err := handle(r); err != nil {
+				return err
+			}
+		case <-timeout:
+		case <-ctx.Done():
+			return nil
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// poll fetches the station CSV and publishes any new readings, unless it
+// is unchanged since the last poll
+func poll(ctx context.Context, validators *validatorCache, seen *dedupe.Filter, topic *queue.Topic, s gauge.Station) report.Span {
+	event, parse := "sepa.recent", parseReadings
+	if s.Type == "rainfall" {
+		event, parse = "sepa.rainfall.recent", parseRainfallReadings
+	}
+	span := report.StartSpan(event).Field("url", s.DataURL)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	resp, v, err := daemon.ConditionalCSV(ctx, s.DataURL, validators.get(s.DataURL))
+	if err != nil {
+		return span.End(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return span.Field("not_modified", true).End()
+	}
+
+	readings, err := parse(resp.Body)
+	if err != nil {
+		return span.End(err)
+	}
+	validators.set(s.DataURL, v)
+	span = span.Field("readings_count", len(readings)).End()
+
+	return span.FollowedBy(seen.Publish(ctx, topic, &gauge.Snapshot{
+		Station:       s,
+		Readings:      readings,
+		CorrelationID: span.TraceID(),
+		CausationID:   span.SpanID(),
+	}))
+}
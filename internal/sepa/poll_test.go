@@ -1,4 +1,4 @@
-package main
+package sepa
 
 import (
 	"context"
@@ -16,7 +16,7 @@ func TestDryRun(t *testing.T) {
 	defer http.DefaultTransport.(*http.Transport).CloseIdleConnections()
 
 	// define an accelerated dry run
-	cfg := config{
+	cfg := Poller{
 		RefreshPeriodInSeconds: 5,
 	}
 
@@ -26,7 +26,7 @@ func TestDryRun(t *testing.T) {
 	d := daemon.New("example")
 
 	// perform the dry run
-	err := cfg.run(ctx, d)
+	err := cfg.Run(ctx, d)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,183 @@
+package sepa
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // the scratch image has no zoneinfo
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+const rainfallURL = "https://www2.sepa.org.uk/rainfall/api/"
+
+// rainfallSource is the feed of hourly rainfall telemetry
+const rainfallSource = "sepa-rainfall"
+
+// rainfallTimeLayouts are the timestamp formats seen in the rainfall CSVs
+var rainfallTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+}
+
+// rainfallZone is the zone of the rainfall CSV timestamps, which are UK
+// local time (BST in summer) rather than UTC
+var rainfallZone = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}()
+
+func discoverRainfall(ctx context.Context) ([]gauge.Station, report.Span) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	url := rainfallURL + "Stations?csv=true"
+	span := report.StartSpan("sepa.rainfall.discover").Field("url", url)
+
+	resp, err := daemon.CSV(ctx, url)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+
+	stations, err := parseRainfallStations(resp.Body)
+	if err != nil {
+		return stations, span.End(err)
+	}
+	span = span.Field("stations_count", len(stations))
+	return stations, span.End()
+}
+
+// parseRainfallStations reads the station list by column header, as the
+// rainfall CSV columns are not in a fixed order, e.g.
+// station_name,station_no,ngr,station_latitude,station_longitude,...
+// Glenfinnan,15201,NM9070080600,56.87,-5.43,...
+func parseRainfallStations(r io.Reader) ([]gauge.Station, error) {
+	var stations []gauge.Station
+	csv := csv.NewReader(r)
+	csv.FieldsPerRecord = -1
+	var columns map[string]int
+
+ReadCSV:
+	for {
+		r, err := csv.Read()
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == io.ErrClosedPipe {
+			break ReadCSV
+		}
+		if err != nil {
+			return stations, err
+		}
+		if columns == nil {
+			columns = make(map[string]int)
+			for i, name := range r {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			if _, ok := columns["station_no"]; !ok {
+				return stations, errors.New("no station_no column in " + strings.Join(r, ","))
+			}
+			continue ReadCSV
+		}
+
+		field := func(names ...string) string {
+			for _, name := range names {
+				if i, ok := columns[name]; ok && i < len(r) {
+					return strings.TrimSpace(r[i])
+				}
+			}
+			return ""
+		}
+
+		id := field("station_no")
+		if id == "" {
+			continue ReadCSV
+		}
+		s := gauge.Station{
+			DataURL:   rainfallURL + "Hourly/" + id + "?csv=true",
+			AliasURL:  "sepa-rainfall://" + id,
+			HumanURL:  "https://www2.sepa.org.uk/rainfall/?station=" + id,
+			Name:      field("station_name"),
+			RiverName: "", // not available
+			Type:      "rainfall",
+			Unit:      "mm",
+		}
+
+		// prefer the grid reference, falling back to lat/lng if it is
+		// missing or invalid as not every station has both
+		lat, lg, err := gridRef2LatLg(field("ngr", "national_grid_reference", "grid_reference"))
+		if err != nil {
+			lat64, latErr := strconv.ParseFloat(field("station_latitude", "latitude"), 32)
+			lg64, lgErr := strconv.ParseFloat(field("station_longitude", "longitude"), 32)
+			if latErr == nil && lgErr == nil {
+				lat, lg, err = float32(lat64), float32(lg64), nil
+			}
+		}
+		if err == nil {
+			s.Lat = lat
+			s.Lg = lg
+		}
+
+		stations = append(stations, s)
+	}
+
+	return stations, nil
+}
+
+// parseRainfallReadings reads hourly totals in mm timestamped in UK local
+// time, e.g.
+// Timestamp,Value
+// 2020-10-06 21:00:00,1.2
+func parseRainfallReadings(r io.Reader) ([]gauge.Reading, error) {
+	var readings []gauge.Reading
+	csv := csv.NewReader(r)
+
+ReadCSV:
+	for {
+		r, err := csv.Read()
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == io.ErrClosedPipe {
+			break ReadCSV
+		}
+		if err != nil {
+			return readings, err
+		}
+		if len(r) != 2 {
+			return readings, errors.New(strconv.Itoa(len(r)) + " rows in " + strings.Join(r, ","))
+		}
+
+		// the header row and missing values are skipped
+		u := gauge.Reading{Quality: gauge.QualityProvisional, Source: rainfallSource}
+		isParsed := false
+		for _, layout := range rainfallTimeLayouts {
+			u.EventTime, err = time.ParseInLocation(layout, r[0], rainfallZone)
+			if err == nil {
+				isParsed = true
+				break
+			}
+		}
+		if !isParsed {
+			continue ReadCSV
+		}
+
+		v, err := strconv.ParseFloat(r[1], 32)
+		if err != nil {
+			continue ReadCSV
+		}
+		u.Value = float32(v)
+
+		readings = append(readings, u)
+	}
+
+	return readings, nil
+}
@@ -0,0 +1,92 @@
+package sepa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestParseRainfallStations(t *testing.T) {
+	const ε = 0.01
+	list := "station_name,station_no,ngr,station_latitude,station_longitude\n" +
+		"Glenfinnan,15201,NM9070080600,56.87,-5.43\n" +
+		"Bad Grid,15202,XX,55.5,-3.5\n" +
+		"No Location,15203,,,\n"
+
+	stations, err := parseRainfallStations(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stations) != 3 {
+		t.Fatal("expected 3 stations, got", len(stations))
+	}
+
+	s := stations[0]
+	if s.Type != "rainfall" || s.Unit != "mm" || s.Name != "Glenfinnan" {
+		t.Error("unexpected station", s)
+	}
+	if s.DataURL != "https://www2.sepa.org.uk/rainfall/api/Hourly/15201?csv=true" || s.AliasURL != "sepa-rainfall://15201" {
+		t.Error("unexpected station URLs", s)
+	}
+	if s.Lat < 56.8 || s.Lat > 56.9 || s.Lg < -5.5 || s.Lg > -5.4 {
+		t.Error("grid reference not converted", s.Lat, s.Lg)
+	}
+
+	if s := stations[1]; s.Lat-55.5 > ε || s.Lg+3.5 > ε {
+		t.Error("expected lat/lng fallback", s.Lat, s.Lg)
+	}
+	if s := stations[2]; s.Lat != 0 || s.Lg != 0 {
+		t.Error("expected no location", s.Lat, s.Lg)
+	}
+}
+
+func TestParseRainfallReadings(t *testing.T) {
+	csv := "Timestamp,Value\n" +
+		"2020-10-06 20:00:00,0.4\n" +
+		"2020-10-06 21:00:00,---\n" +
+		"06/10/2020 22:00:00,1.2\n"
+
+	readings, err := parseRainfallReadings(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 2 {
+		t.Fatal("expected 2 readings, got", readings)
+	}
+	expected := time.Date(2020, 10, 6, 21, 0, 0, 0, time.UTC) // 22:00 BST
+	if !readings[1].EventTime.Equal(expected) || readings[1].Value != 1.2 {
+		t.Error("unexpected reading", readings[1])
+	}
+	if readings[1].Quality != gauge.QualityProvisional || readings[1].Source != "sepa-rainfall" {
+		t.Error("unexpected quality", readings[1])
+	}
+}
+
+func TestParseRainfallReadingsAcrossBST(t *testing.T) {
+	csv := "Timestamp,Value\n" +
+		"2020-03-29 00:00:00,0.1\n" +
+		"2020-03-29 02:00:00,0.2\n" +
+		"2020-10-25 00:00:00,0.3\n" +
+		"2020-10-25 02:00:00,0.4\n"
+
+	readings, err := parseRainfallReadings(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{
+		time.Date(2020, 3, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 29, 1, 0, 0, 0, time.UTC),
+		time.Date(2020, 10, 24, 23, 0, 0, 0, time.UTC),
+		time.Date(2020, 10, 25, 2, 0, 0, 0, time.UTC),
+	}
+	if len(readings) != len(expected) {
+		t.Fatal("expected", len(expected), "readings, got", readings)
+	}
+	for i, r := range readings {
+		if !r.EventTime.Equal(expected[i]) {
+			t.Error("reading", i, "at", r.EventTime.UTC(), "expected", expected[i])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sepa
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// schedule tracks when each station is next due a poll
+//
+// Priority stations are polled first whenever several are due, and the
+// others on the slower background refresh period. A station is not polled
+// again once it has been polled `cycles` times (when cycles > 0).
+type schedule struct {
+	cycles      int
+	hasPriority bool
+	stations    []gauge.Station
+	isPriority  []bool
+	due         []time.Time
+	polls       []int
+	inFlight    []bool
+	nInFlight   int
+}
+
+func newSchedule(stations []gauge.Station, priority gauge.Priority, cycles int, now time.Time) *schedule {
+	s := &schedule{
+		cycles:      cycles,
+		hasPriority: len(priority) > 0,
+		stations:    stations,
+		isPriority:  make([]bool, len(stations)),
+		due:         make([]time.Time, len(stations)),
+		polls:       make([]int, len(stations)),
+		inFlight:    make([]bool, len(stations)),
+	}
+	for i, station := range stations {
+		s.isPriority[i] = priority.Contains(station)
+		s.due[i] = now
+	}
+	return s
+}
+
+// next provides the index of the station to poll now, or -1 with the wait
+// until one is due (a negative wait if only an in-flight poll can free one)
+func (s *schedule) next(now time.Time, concurrency int) (int, time.Duration) {
+	if s.nInFlight >= concurrency {
+		return -1, -1
+	}
+
+	i := -1
+	wait := time.Duration(-1)
+	for j := range s.stations {
+		if s.inFlight[j] || (s.cycles > 0 && s.polls[j] >= s.cycles) {
+			continue
+		}
+		if s.due[j].After(now) {
+			if until := s.due[j].Sub(now); wait < 0 || until < wait {
+				wait = until
+			}
+			continue
+		}
+		if i < 0 || s.isBefore(j, i) {
+			i = j
+		}
+	}
+	if i < 0 {
+		return -1, wait
+	}
+	return i, 0
+}
+
+// isBefore is true if station i should be polled before station j
+func (s *schedule) isBefore(i, j int) bool {
+	if s.isPriority[i] != s.isPriority[j] {
+		return s.isPriority[i]
+	}
+	return s.due[i].Before(s.due[j])
+}
+
+func (s *schedule) start(i int) {
+	s.inFlight[i] = true
+	s.nInFlight++
+}
+
+// done records a finished poll, with a station not in a non-empty priority
+// set next due after the background period
+func (s *schedule) done(i int, started time.Time, every time.Duration, backgroundEvery time.Duration) {
+	s.inFlight[i] = false
+	s.nInFlight--
+	s.polls[i]++
+	if s.hasPriority && !s.isPriority[i] {
+		s.due[i] = started.Add(backgroundEvery)
+	} else {
+		s.due[i] = started.Add(every)
+	}
+}
+
+// isComplete is true once every station has been polled the number of cycles
+func (s *schedule) isComplete() bool {
+	if s.cycles == 0 || s.nInFlight > 0 {
+		return false
+	}
+	for _, n := range s.polls {
+		if n < s.cycles {
+			return false
+		}
+	}
+	return true
+}
+
+// priorityCount is the number of priority stations
+func (s *schedule) priorityCount() int {
+	n := 0
+	for _, p := range s.isPriority {
+		if p {
+			n++
+		}
+	}
+	return n
+}
+
+// tokenBucket limits the request rate while allowing a short burst
+type tokenBucket struct {
+	every  time.Duration
+	burst  int
+	tokens int
+	last   time.Time
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	if perSecond <= 0 {
+		perSecond = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		every:  time.Duration(float64(time.Second) / perSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available, false if ctx is done first
+func (b *tokenBucket) wait(ctx context.Context) bool {
+	for {
+		now := time.Now()
+		if n := int(now.Sub(b.last) / b.every); n > 0 {
+			b.tokens += n
+			b.last = b.last.Add(time.Duration(n) * b.every)
+			if b.tokens >= b.burst {
+				b.tokens = b.burst
+				b.last = now
+			}
+		}
+		if b.tokens > 0 {
+			b.tokens--
+			return true
+		}
+
+		timer := time.NewTimer(b.last.Add(b.every).Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// validatorCache holds the validators of the last response for each URL
+type validatorCache struct {
+	mu         sync.Mutex
+	validators map[string]daemon.Validators
+}
+
+func (c *validatorCache) get(url string) daemon.Validators {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.validators[url]
+}
+
+func (c *validatorCache) set(url string, v daemon.Validators) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.validators == nil {
+		c.validators = make(map[string]daemon.Validators)
+	}
+	c.validators[url] = v
+}
@@ -0,0 +1,141 @@
+package sepa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/dedupe"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+func TestScheduleOrdersPriorityFirst(t *testing.T) {
+	now := time.Date(2020, 10, 6, 20, 0, 0, 0, time.UTC)
+	stations := []gauge.Station{
+		{DataURL: "a", AliasURL: "sepa://1"},
+		{DataURL: "b", AliasURL: "sepa://2"},
+		{DataURL: "c", AliasURL: "sepa://3"},
+	}
+	s := newSchedule(stations, gauge.NewPriority([]string{"sepa://3"}), 0, now)
+
+	var order []string
+	for {
+		i, _ := s.next(now, 10)
+		if i < 0 {
+			break
+		}
+		s.start(i)
+		order = append(order, stations[i].DataURL)
+	}
+	if fmt.Sprint(order) != "[c a b]" {
+		t.Error("unexpected order", order)
+	}
+	// priority stations are due again sooner
+	for i := range stations {
+		s.done(i, now, 5*time.Minute, time.Hour)
+	}
+	i, wait := s.next(now, 10)
+	if i != -1 || wait != 5*time.Minute {
+		t.Error("expected wait for priority station", i, wait)
+	}
+	if i, _ := s.next(now.Add(5*time.Minute), 10); i != 2 {
+		t.Error("expected priority station due", i)
+	}
+}
+
+func TestScheduleLimitsConcurrency(t *testing.T) {
+	now := time.Now()
+	s := newSchedule([]gauge.Station{{DataURL: "a"}, {DataURL: "b"}}, nil, 0, now)
+	i, _ := s.next(now, 1)
+	s.start(i)
+	if i, wait := s.next(now, 1); i != -1 || wait >= 0 {
+		t.Error("expected to wait for the in-flight poll", i, wait)
+	}
+}
+
+func TestScheduleCompletesCycles(t *testing.T) {
+	now := time.Now()
+	s := newSchedule([]gauge.Station{{DataURL: "a"}}, nil, 1, now)
+	i, _ := s.next(now, 1)
+	s.start(i)
+	if s.isComplete() {
+		t.Error("complete with a poll in flight")
+	}
+	s.done(i, now, 0, 0)
+	if !s.isComplete() {
+		t.Error("expected complete after one cycle")
+	}
+	if i, _ := s.next(now, 1); i != -1 {
+		t.Error("station polled beyond its cycles")
+	}
+}
+
+func TestTokenBucketLimitsRate(t *testing.T) {
+	b := newTokenBucket(50, 1)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if !b.wait(context.Background()) {
+			t.Fatal("unexpected wait failure")
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Error("5 tokens at 50/s took only", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if b.wait(ctx) {
+		t.Error("expected no token once cancelled")
+	}
+}
+
+func TestPollSkipsUnchangedCSV(t *testing.T) {
+	nRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("Date,Level\n06/10/2020 20:45:00,0.642\n"))
+	}))
+	defer srv.Close()
+
+	broker := queue.NewMemory()
+	broker.CreateGroup("test")
+	topic := queue.NewTopic(broker)
+	validators := &validatorCache{}
+	seen, _ := dedupe.New("")
+	station := gauge.Station{DataURL: srv.URL, Type: "level"}
+
+	span := poll(context.Background(), validators, seen, topic, station)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	span = poll(context.Background(), validators, seen, topic, station)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if nRequests != 2 {
+		t.Error("expected 2 requests, got", nRequests)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	nSnapshots := 0
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		nSnapshots++
+		if broker.Idle() {
+			cancel()
+		}
+		return err
+	})
+	if nSnapshots != 1 {
+		t.Error("expected the unchanged CSV to be skipped, got", nSnapshots, "snapshots")
+	}
+}
@@ -0,0 +1,55 @@
+package sepa
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// levelSource is the feed of recent level telemetry
+const levelSource = "sepa-level"
+
+// parseReadings reads recent levels, e.g.
+// Date,Level
+// 06/10/2020 20:45:00,0.642
+func parseReadings(r io.Reader) ([]gauge.Reading, error) {
+	var readings []gauge.Reading
+	csv := csv.NewReader(r)
+
+ReadCSV:
+	for {
+		r, err := csv.Read()
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF || err == io.ErrClosedPipe {
+			break ReadCSV
+		}
+		if err != nil {
+			return readings, err
+		}
+		if len(r) != 2 {
+			return readings, errors.New(strconv.Itoa(len(r)) + " rows in " + strings.Join(r, ","))
+		}
+
+		u := gauge.Reading{Quality: gauge.QualityProvisional, Source: levelSource}
+
+		u.EventTime, err = time.Parse("02/01/2006 15:04:05", r[0])
+		if err != nil {
+			continue ReadCSV
+		}
+
+		v, err := strconv.ParseFloat(r[1], 32)
+		if err != nil {
+			continue ReadCSV
+		}
+		u.Value = float32(v)
+
+		readings = append(readings, u)
+	}
+
+	return readings, nil
+}
@@ -0,0 +1,48 @@
+package sepa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/dedupe"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/queue"
+)
+
+func TestUpdatingFromAStation(t *testing.T) {
+	broker := queue.SharedMemory("sepa-update")
+	broker.CreateGroup("test")
+	topic := queue.NewTopic(broker)
+	seen, span := dedupe.New("")
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	station := gauge.Station{
+		DataURL: "https://www2.sepa.org.uk/waterlevels/CSVs/116011-SG.csv",
+		Type:    "level",
+	}
+	span = poll(context.Background(), &validatorCache{}, seen, topic, station)
+	if err := span.Err(); err != nil {
+		t.Fatal("Update stations error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var readings []gauge.Reading
+	topic.Subscribe(ctx, "test", func(ctx context.Context, err error, s *gauge.Snapshot) error {
+		readings = append(readings, s.Readings...)
+		cancel()
+		return err
+	})
+
+	if len(readings) < 50 {
+		t.Error("Not enough readings found", len(readings))
+	}
+	for i, u := range readings {
+		if u.EventTime.IsZero() {
+			t.Error("No EventTime", i)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package store
 
 import (
 	"context"
@@ -9,7 +9,7 @@ import (
 	"github.com/robtuley/report"
 )
 
-// AlgoliaWriter handles algolia writes
+// AlgoliaWriter is an algolia search indexer
 type AlgoliaWriter struct {
 	RiverIndex   algoliasearch.Index
 	StationIndex algoliasearch.Index
@@ -32,26 +32,12 @@ func (aw *AlgoliaWriter) StoreRecord(ctx context.Context, record *Record) report
 	defer cancel()
 
 	uuid := record.Section.UUID
-	s := record.Section
-	l := record.Level
-
-	span := report.StartSpan("algolia.store").Field("uuid", s.UUID)
-	object := algoliasearch.Object{
-		"objectID":      uuid,
-		"slug":          s.Slug,
-		"section":       s.SectionName,
-		"river":         s.RiverName,
-		"grade":         s.Grade.Human,
-		"grade_numeric": s.Grade.Average,
-		"desc":          s.Description,
-		"km":            s.KM,
-		"_geoloc": map[string]float32{
-			"lat": s.Putin.Lat,
-			"lng": s.Putin.Lng,
-		},
-		"level_label":     l.Label,
-		"level_reason":    l.Reason,
-		"level_timestamp": l.EventTime,
+	span := report.StartSpan("algolia.store").Field("uuid", uuid)
+	object := algoliasearch.Object(recordFields(record))
+	object["objectID"] = uuid
+	object["_geoloc"] = map[string]float32{
+		"lat": record.Section.Putin.Lat,
+		"lng": record.Section.Putin.Lng,
 	}
 	_, err := aw.RiverIndex.UpdateObject(object)
 	if err != nil {
@@ -67,17 +53,11 @@ func (aw *AlgoliaWriter) StoreStation(ctx context.Context, station gauge.Station
 	defer cancel()
 
 	span := report.StartSpan("algolia.store").Field("data_url", station.DataURL)
-	object := algoliasearch.Object{
-		"objectID":  station.DataURL,
-		"alias_url": station.AliasURL,
-		"human_url": station.HumanURL,
-		"name":      station.Name,
-		"river":     station.RiverName,
-		"type":      station.Type,
-		"_geoloc": map[string]float32{
-			"lat": station.Lat,
-			"lng": station.Lg,
-		},
+	object := algoliasearch.Object(stationFields(station))
+	object["objectID"] = station.DataURL
+	object["_geoloc"] = map[string]float32{
+		"lat": station.Lat,
+		"lng": station.Lg,
 	}
 	_, err := aw.StationIndex.UpdateObject(object)
 	if err != nil {
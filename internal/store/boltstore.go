@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+	bolt "go.etcd.io/bbolt"
+)
+
+var boltBucket = []byte("rivers")
+
+// BoltStore persists records in an embedded bbolt database file
+//
+// The database is held open until closed, so the file cannot be shared with
+// another process (e.g. cmd/web) at the same time.
+type BoltStore struct {
+	Path string
+
+	db *bolt.DB
+}
+
+// NewBoltStore opens the database file, creating it if it does not yet exist
+func NewBoltStore(path string) (*BoltStore, report.Span) {
+	span := report.StartSpan("boltstore.connect").Field("path", path)
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, span.End(err)
+	}
+	bs := &BoltStore{
+		Path: path,
+		db:   db,
+	}
+	err = bs.update(func(b *bolt.Bucket) error {
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, span.End(err)
+	}
+	return bs, span.End()
+}
+
+// Close releases the database file
+func (bs *BoltStore) Close() {
+	bs.db.Close()
+}
+
+// LoadAndUpdate resets the record if the section has changed
+func (bs *BoltStore) LoadAndUpdate(ctx context.Context, s river.Section) (bool, *Record, report.Span) {
+	span := report.StartSpan("boltstore.loadandupdate").Field("uuid", s.UUID)
+	return loadAndUpdate(ctx, bs, s, span)
+}
+
+// Load retrieves the latest river from the database
+func (bs *BoltStore) Load(ctx context.Context, uuid string) (*Record, report.Span) {
+	span := report.StartSpan("boltstore.load").Field("uuid", uuid)
+
+	var v []byte
+	err := bs.view(func(b *bolt.Bucket) error {
+		v = append(v, b.Get([]byte(uuid))...)
+		return nil
+	})
+	if err != nil {
+		return nil, span.End(err)
+	}
+	if v == nil {
+		return nil, span.End()
+	}
+
+	var record Record
+	if err := json.Unmarshal(v, &record); err != nil {
+		// as with firestore, a format change is an indication to re-write
+		span = span.Field("corruption", err.Error())
+		return nil, span.End()
+	}
+	return &record, span.End()
+}
+
+// Store saves the river to the database
+func (bs *BoltStore) Store(ctx context.Context, record *Record) report.Span {
+	uuid := record.Section.UUID
+	span := report.StartSpan("boltstore.store").Field("uuid", uuid)
+
+	v, err := json.Marshal(record)
+	if err != nil {
+		return span.End(err)
+	}
+	err = bs.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(uuid), v)
+	})
+	return span.End(err)
+}
+
+func (bs *BoltStore) update(fn func(b *bolt.Bucket) error) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+func (bs *BoltStore) view(fn func(b *bolt.Bucket) error) error {
+	return bs.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltBucket)
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
@@ -0,0 +1,734 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers"
+	"github.com/robtuley/rainchasers/internal/content"
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/history"
+	"github.com/robtuley/rainchasers/internal/queue"
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+)
+
+// Cache keeps river records up to date from the gauge snapshot stream
+//
+// Records are kept in a bbolt file if BoltPath is set, otherwise firestore
+// if ProjectID is set, otherwise in memory only. Rivers and stations are
+// indexed in algolia if AlgoliaAppID is set, otherwise meilisearch if
+// MeiliURL is set, otherwise not at all. Every reading is also kept in a
+// long-term history if HistoryPath is set. The records and history are
+// served over HTTP on HTTPAddr if set, so cmd/web can read them while they
+// are held open here.
+type Cache struct {
+	ProjectID      string
+	TopicName      string
+	QueueURL       string
+	ConsumerGroup  string // zero length is an ephemeral subscription
+	SchemaDir      string // extra snapshot schemas, blank uses those compiled in
+	DeadLetterURL  string // queue for corrupted messages, blank drops them
+	ContentDir     string // rivers/*.yaml directory watched for changes, blank uses compiled in content
+	BoltPath       string
+	HistoryPath    string
+	HTTPAddr       string // e.g. :8081 to serve records & history, blank serves neither
+	AlgoliaAppID   string
+	AlgoliaAPIKey  string
+	MeiliURL       string
+	MeiliAPIKey    string
+	ReadyC         chan struct{}
+	Log            *report.Logger
+	Records        RecordStore
+	Search         SearchIndexer
+	History        *history.Store
+	SnapRoute      map[string][]*Mailbox
+	StationUpdated map[string]bool
+	Now            func() time.Time // clock to expire old readings against
+	TrendWindow    time.Duration    // period of readings to calculate trend over
+	MailboxSize    int              // stations that can wait for each section writer
+	MaxLag         time.Duration    // wait for a section writer before logging it as lagging
+
+	inFlight  sync.WaitGroup
+	processed *processed
+	stationMu sync.Mutex // guards StationUpdated as snapshots are routed concurrently
+	applyMu   sync.Mutex
+	routeMu   sync.RWMutex // guards SnapRoute and writers
+	writers   map[string]*sectionWriter
+}
+
+// New creates an empty cache ready to be configured
+func New(log *report.Logger) *Cache {
+	return &Cache{
+		ReadyC:         make(chan struct{}),
+		Log:            log,
+		SnapRoute:      make(map[string][]*Mailbox),
+		StationUpdated: make(map[string]bool),
+		Now:            time.Now,
+		TrendWindow:    DefaultTrendWindow,
+		MailboxSize:    DefaultMailboxSize,
+		MaxLag:         DefaultMaxLag,
+		processed:      newProcessed(processedSize),
+	}
+}
+
+// Close releases the record store and history once every writer has stopped
+func (c *Cache) Close() {
+	if c.Records != nil {
+		c.Records.Close()
+	}
+	if c.History != nil {
+		c.History.Close()
+	}
+}
+
+// Settle blocks until every routed snapshot has been processed
+func (c *Cache) Settle() {
+	c.inFlight.Wait()
+}
+
+// Init loads each section record and starts a writer for those calibrated
+func (c *Cache) Init(ctx context.Context, d *daemon.Supervisor) error {
+	// connect to the record store & search unless already configured
+	if c.Records == nil {
+		rs, span := OpenRecordStore(c.BoltPath, c.ProjectID)
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+		c.Records = rs
+	}
+	if c.Search == nil {
+		si, span := c.connectSearchIndexer(ctx)
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+		c.Search = si
+	}
+	if c.History == nil && c.HistoryPath != "" {
+		hs, span := history.New(c.HistoryPath)
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+		c.History = hs
+	}
+	if c.History != nil {
+		d.Run(ctx, c.PruneHistory)
+	}
+	if c.HTTPAddr != "" {
+		d.Run(ctx, c.Serve)
+	}
+
+	// load the river content, watching the directory for changes if set
+	cn := content.New(rainchasers.Sections, rainchasers.Calibrations)
+	if c.ContentDir != "" {
+		span := report.StartSpan("content.loaded").Field("dir", c.ContentDir)
+		var err error
+		cn, err = content.Load(c.ContentDir)
+		d.Trace(span.End(err))
+		if err != nil {
+			return err
+		}
+		d.Run(ctx, content.NewWatcher(c.ContentDir, func(ctx context.Context, cn *content.Content) {
+			if err := c.Apply(ctx, d, cn); err != nil {
+				c.Log.Action("content.apply", report.Data{
+					"error": err.Error(),
+				})
+			}
+		}).Run)
+	}
+	if err := c.Apply(ctx, d, cn); err != nil {
+		return err
+	}
+
+	close(c.ReadyC)
+	return nil
+}
+
+// sectionWriter is the running writer of a section, or nil mailbox if the
+// section is not calibrated
+type sectionWriter struct {
+	checksum string // of the section and calibrations it was started with
+	mailbox  *Mailbox
+	cancel   func()
+	done     chan struct{}
+}
+
+func (w *sectionWriter) stop() {
+	if w.mailbox != nil {
+		w.cancel()
+		<-w.done
+	}
+}
+
+// Apply updates the record of each section in the content and rebuilds the
+// snapshot routing table, restarting the writer of any section whose
+// definition or calibrations have changed and stopping those removed
+//
+// Snapshots waiting for a stopped writer are saved before its replacement
+// starts, and any routed to it once stopped are nacked so they are
+// redelivered to the replacement.
+func (c *Cache) Apply(ctx context.Context, d *daemon.Supervisor, cn *content.Content) error {
+	c.applyMu.Lock()
+	defer c.applyMu.Unlock()
+
+	c.routeMu.RLock()
+	prev := c.writers
+	c.routeMu.RUnlock()
+
+	// update catalogue in the record store (rate limited for firestore)
+	_, isRateLimited := c.Records.(*FireWriter)
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	writers := make(map[string]*sectionWriter, len(cn.Sections))
+	route := make(map[string][]*Mailbox)
+updateLoop:
+	for _, s := range cn.Sections {
+		calibrations, isCalibrated := cn.Calibrations[s.UUID]
+		sum := checksum(s, calibrations)
+
+		// an unchanged section keeps its existing writer
+		if w, ok := prev[s.UUID]; ok && w.checksum == sum {
+			writers[s.UUID] = w
+			if isCalibrated {
+				for _, m := range calibrations {
+					route[m.URL] = append(route[m.URL], w.mailbox)
+				}
+			}
+			continue updateLoop
+		}
+
+		// stop the writer of a changed section before its record is reloaded
+		if w, ok := prev[s.UUID]; ok {
+			w.stop()
+		}
+
+		// get stored info for the section
+		// (& update if necessary and in search index if changed)
+		hasChanged, record, span := c.Records.LoadAndUpdate(ctx, s)
+		if hasChanged && c.Search != nil {
+			sSpan := c.Search.StoreRecord(ctx, record)
+			span = span.FollowedBy(sSpan)
+		}
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+
+		// if calibration exists then launch goroutine
+		// to listen to snapshots and update river
+		w := &sectionWriter{checksum: sum}
+		writers[s.UUID] = w
+		if isCalibrated {
+			w.mailbox = NewMailbox(c.MailboxSize)
+			w.done = make(chan struct{})
+			var wctx context.Context
+			wctx, w.cancel = context.WithCancel(context.Background())
+
+			// add to routing table
+			for _, m := range calibrations {
+				route[m.URL] = append(route[m.URL], w.mailbox)
+			}
+
+			fn := c.CreateSnapshotsWriter(*record, calibrations, w.mailbox)
+			done := w.done
+			d.Run(wctx, func(ctx context.Context, d *daemon.Supervisor) error {
+				defer close(done)
+				return fn(ctx, d)
+			})
+		}
+
+		// no need to rate limit a local store
+		if !isRateLimited {
+			continue updateLoop
+		}
+		select {
+		case <-ctx.Done():
+			break updateLoop
+		case <-ticker.C:
+		}
+	}
+
+	// swap in the new routing table, then stop writers of removed sections
+	c.routeMu.Lock()
+	c.SnapRoute = route
+	c.writers = writers
+	c.routeMu.Unlock()
+	for uuid, w := range prev {
+		if _, ok := writers[uuid]; !ok {
+			w.stop()
+		}
+	}
+	return nil
+}
+
+func (c *Cache) connectSearchIndexer(ctx context.Context) (SearchIndexer, report.Span) {
+	if c.AlgoliaAppID != "" {
+		span := report.StartSpan("algolia.connect").End()
+		return NewAlgoliaWriter(c.AlgoliaAppID, c.AlgoliaAPIKey), span
+	}
+	if c.MeiliURL != "" {
+		return NewMeiliIndexer(ctx, c.MeiliURL, c.MeiliAPIKey)
+	}
+	return nil, report.StartSpan("search.disabled").End()
+}
+
+// CreateSnapshotsWriter creates a routine that merges snapshots into a river record
+func (c *Cache) CreateSnapshotsWriter(record Record, calibrations []river.Calibration, mb *Mailbox) func(ctx context.Context, d *daemon.Supervisor) error {
+	return func(ctx context.Context, d *daemon.Supervisor) error {
+		// the calibrations may have changed on previously inited measures
+		// that have been pulled from firestore, so reset the calibrations
+		// stored against each measure. if a calibration no longer exists, the
+		// measure should be deleted.
+		for i := range record.Measures {
+			cal, exists := findCalibrationForStation(calibrations, record.Measures[i].Station)
+			if exists {
+				record.Measures[i].Calibration = cal
+			} else {
+				// delete this index from the slice
+				record.Measures = append(record.Measures[:i], record.Measures[i+1:]...)
+			}
+		}
+
+		// now we have updated the measures, we start building the map of where to
+		// route snapshots too. Where a snapshot misses (i.e. a new measure), this
+		// is lazy-inited as we need to wait for a snapshot with the station info in
+		aliasURLToIndex := make(map[string]int)
+		for i, m := range record.Measures {
+			aliasURLToIndex[m.Station.AliasURL] = i
+		}
+
+		const missingAfter = 4 * time.Hour
+		missing := time.NewTicker(missingAfter)
+		defer missing.Stop()
+		freshness := time.NewTicker(freshnessCheckPeriod)
+		defer freshness.Stop()
+
+		// process merges a delivery into the record, true if it changed
+		process := func(delivery Delivery) (report.Span, bool, error) {
+			snap := delivery.Snapshot
+
+			// route snap to existing or create new measure
+			index, ok := aliasURLToIndex[snap.Station.AliasURL]
+			if !ok {
+				// this must be the first snapshot of a lazy-inited measure
+				// so we need to search for the calibration and then setup
+				// the new measure ready to receive further snapshots
+				cal, exists := findCalibrationForStation(calibrations, snap.Station)
+				if !exists {
+					// this must be code logic as this routine should only receive
+					// snaps that have a calibration for (even if that calibration is empty)
+					msg := record.Section.UUID + " with snap " + snap.Station.AliasURL
+					return report.Span{}, false, errors.New("incorrectly routed snapshot: " + msg)
+				}
+
+				// append a new measure to the river with calibration and station
+				// (readings will be added in the normal snapshot processing later)
+				index = len(record.Measures)
+				record.Measures = append(record.Measures, Measure{
+					Station:     snap.Station,
+					Calibration: cal,
+					// no readings
+				})
+				aliasURLToIndex[snap.Station.AliasURL] = index
+			}
+
+			// now we know the index we're putting this snapshot into (and have
+			// created a placeholder for it if it didn't exist), we merge in the
+			// snapshot readings with the existing measure ones and save if changed
+			span := report.StartSpan("snapshot.saved",
+				report.TraceID(snap.CorrelationID), report.ParentSpanID(snap.CausationID))
+			span = span.Field("section_uuid", record.Section.UUID)
+			span = span.Field("alias_url", snap.Station.AliasURL)
+			span = span.Field("quality_checked", snap.QualityChecked)
+
+			m := record.Measures[index]
+
+			// a redelivery after a restart (when the processed keys in memory
+			// are lost) is recognised by the last snapshot saved to the measure
+			if key := processedKey(snap); key != "" && key == m.LastSnapshot {
+				c.Log.Info("snapshot.duplicate", report.Data{
+					"correlation_id": snap.CorrelationID,
+					"data_url":       snap.Station.DataURL,
+				})
+				return span, false, nil
+			}
+
+			// checksum only the readings and station (as they are being potentially
+			// changed). If checksum the whole measure, the processedTim field will
+			// force a push on every snapshot received.
+			prevChecksum := checksum(m.Readings, m.Station)
+
+			// merge snapshot into the measure
+			m.Readings = merge(m.Readings, snap.Readings)
+
+			// we need to clean up old data, but don't want the cleanup to result in
+			// more data pushes to firestore/algolia than necessary. so we "round"
+			// the expiry to the nearest midnight so a data push for cleanup should
+			// happen max once a day
+			expiry := c.Now().Add(-3 * 24 * time.Hour).Truncate(24 * time.Hour)
+			removeOlderThan(expiry, &m.Readings)
+
+			// work out if this has resulted in a change
+			m.Station = snap.Station
+			if prevChecksum == checksum(m.Readings, m.Station) {
+				return span, false, nil
+			}
+			m.ProcessedTime = snap.ProcessedTime
+			m.LastSnapshot = processedKey(snap)
+			record.Measures[index] = m
+
+			// use updated measures to re-calulate level state
+			record.Level = resolveLevel(record.Section.Strategy, calibrations, record.Measures, c.Now(), c.TrendWindow)
+			span = span.Field("stale", record.Level.Stale)
+			return span, true, nil
+		}
+
+		// a record that failed to save is saved again with the next
+		// delivery, even if that is unchanged (e.g. the nacked redelivery)
+		isUnsaved := false
+		save := func(ctx context.Context, span report.Span) error {
+			rSpan := c.Records.Store(ctx, &record)
+			span = span.Child(rSpan)
+			err := rSpan.Err()
+			if c.Search != nil {
+				sSpan := c.Search.StoreRecord(ctx, &record)
+				span = span.Child(sSpan)
+				if err == nil {
+					err = sSpan.Err()
+				}
+			}
+			isUnsaved = err != nil
+			c.Log.Trace(span.End())
+			return err
+		}
+
+	nextSnapshot:
+		for {
+			select {
+			case <-ctx.Done():
+				// anything waiting is saved before the writer stops (and its
+				// replacement reloads the record), then acked with the result
+				span := report.StartSpan("section.flushed").Field("section_uuid", record.Section.UUID)
+				pending := mb.Close()
+				span = span.Field("mailbox_depth", len(pending))
+				isChanged := isUnsaved
+				var err error
+				for _, delivery := range pending {
+					_, changed, pErr := process(delivery)
+					if pErr != nil {
+						err = pErr
+					}
+					isChanged = isChanged || changed
+				}
+				if isChanged {
+					fctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+					if sErr := save(fctx, span); err == nil {
+						err = sErr
+					}
+					cancel()
+				}
+				for _, delivery := range pending {
+					c.ack(delivery, err)
+				}
+				return nil
+			case <-missing.C:
+				// if no snapshot received for some time there is
+				// some sort of upstream problem
+				c.Log.Action("snapshot.missing", report.Data{
+					"section_uuid": record.Section.UUID,
+				})
+				continue nextSnapshot
+			case <-freshness.C:
+				// readings become stale without any new snapshot
+				c.refreshLevel(ctx, &record, calibrations)
+				continue nextSnapshot
+			case <-mb.Ready():
+			}
+			delivery, ok := mb.Take()
+			if !ok {
+				continue nextSnapshot
+			}
+			missing.Reset(missingAfter)
+
+			// a section writer that falls behind is logged, as its snapshots
+			// are being coalesced (or dropped if many stations are waiting)
+			lag := time.Since(delivery.queuedAt)
+			if lag > c.MaxLag {
+				depth, nCoalesced, nDropped := mb.Stats()
+				c.Log.Action("section.lagging", report.Data{
+					"section_uuid":    record.Section.UUID,
+					"lag_ms":          lag.Milliseconds(),
+					"mailbox_depth":   depth,
+					"coalesced_count": nCoalesced,
+					"dropped_count":   nDropped,
+				})
+			}
+
+			span, isChanged, err := process(delivery)
+			if err != nil {
+				c.ack(delivery, err)
+				return err
+			}
+			if !isChanged && !isUnsaved {
+				// measure has not changed wait for next one
+				c.ack(delivery, nil)
+				continue nextSnapshot
+			}
+
+			// write the update to the record store & search index, and only
+			// then ack every message coalesced into the delivery
+			depth, _, _ := mb.Stats()
+			span = span.Field("mailbox_depth", depth)
+			span = span.Field("coalesced_count", len(delivery.done))
+			span = span.Field("lag_ms", lag.Milliseconds())
+			c.ack(delivery, save(ctx, span))
+		}
+	}
+}
+
+// ack sends the result of storing a delivery to the router of each of its
+// snapshots
+func (c *Cache) ack(delivery Delivery, err error) {
+	for _, done := range delivery.done {
+		done <- err
+		c.inFlight.Done()
+	}
+}
+
+// refreshLevel saves the level if it has changed as the readings have aged
+func (c *Cache) refreshLevel(ctx context.Context, record *Record, calibrations []river.Calibration) {
+	l := resolveLevel(record.Section.Strategy, calibrations, record.Measures, c.Now(), c.TrendWindow)
+	if l.Label == record.Level.Label && l.Stale == record.Level.Stale {
+		return
+	}
+	record.Level = l
+
+	span := report.StartSpan("level.refreshed").Field("section_uuid", record.Section.UUID)
+	span = span.Field("label", l.Label).Field("stale", l.Stale)
+	span = span.Child(c.Records.Store(ctx, record))
+	if c.Search != nil {
+		span = span.Child(c.Search.StoreRecord(ctx, record))
+	}
+	c.Log.Trace(span.End())
+
+	if l.Stale {
+		c.Log.Info("level.stale", report.Data{
+			"section_uuid": record.Section.UUID,
+			"reason":       l.Reason,
+		})
+	}
+}
+
+// PruneHistory removes expired history once an hour
+func (c *Cache) PruneHistory(ctx context.Context, d *daemon.Supervisor) error {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		d.Trace(c.History.Prune(ctx, c.Now()))
+	}
+}
+
+// Serve provides the records below /records/ and the history at /history
+// to other processes until the context is done
+func (c *Cache) Serve(ctx context.Context, d *daemon.Supervisor) error {
+	mux := http.NewServeMux()
+	mux.Handle("/records/", RecordHandler(c.Records))
+	if c.History != nil {
+		mux.Handle("/history", history.Handler(c.History))
+	}
+	srv := &http.Server{Addr: c.HTTPAddr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	d.Info("http.start", report.Data{"addr": c.HTTPAddr})
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// SubscribeToSnapshots routes snapshots from the queue once init is complete
+func (c *Cache) SubscribeToSnapshots(ctx context.Context, d *daemon.Supervisor) error {
+	// wait for init
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-c.ReadyC:
+	}
+
+	// connect to pubsub
+	topic, span := queue.Connect(ctx, c.QueueURL, c.ProjectID, c.TopicName)
+	d.Trace(span)
+	if err := span.Err(); err != nil {
+		return err
+	}
+	defer topic.Stop()
+
+	// corrupted messages are kept on a dead-letter queue if configured
+	if c.DeadLetterURL != "" {
+		dl, span := queue.Open(ctx, c.DeadLetterURL)
+		d.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+		defer dl.Stop()
+		topic.UseDeadLetter(dl)
+	}
+
+	// newer writer schemas than those compiled in during a rolling deploy
+	if c.SchemaDir != "" {
+		span := report.StartSpan("schemas.loaded").Field("dir", c.SchemaDir)
+		schemas, err := gauge.LoadRegistry(c.SchemaDir)
+		d.Trace(span.End(err))
+		if err != nil {
+			return err
+		}
+		topic.UseSchemas(schemas)
+	}
+
+	// subscribe! (the router waits for snapshots to be stored, so those of a
+	// batch are routed together)
+	topic.UseConcurrentBatches()
+	return topic.Subscribe(ctx, c.ConsumerGroup, c.SnapshotRouter)
+}
+
+// SnapshotRouter passes a snapshot to the writers of each section it
+// calibrates, and returns once each of them has stored it
+//
+// It is safe to call concurrently (as the brokers deliver messages), and a
+// section writer only holds up the messages routed to it.
+//
+// Note: only return error if want message redelivered, otherwise deal with it locally
+func (c *Cache) SnapshotRouter(ctx context.Context, err error, s *gauge.Snapshot) error {
+	if err != nil {
+		c.Log.Action("snapshot.corrupted", report.Data{
+			"error":         err.Error(),
+			"dead_lettered": c.DeadLetterURL != "",
+		})
+		return nil // error with decoding so do not retry delivery
+	}
+
+	// a redelivered message that has already been stored is acked again
+	if c.processed.has(s) {
+		c.Log.Info("snapshot.duplicate", report.Data{
+			"correlation_id": s.CorrelationID,
+			"data_url":       s.Station.DataURL,
+		})
+		return nil
+	}
+
+	// keep every reading in the long-term history, retrying delivery on failure
+	if c.History != nil {
+		span := c.History.Append(ctx, s.Station.DataURL, s.Readings)
+		c.Log.Trace(span)
+		if err := span.Err(); err != nil {
+			return err
+		}
+	}
+
+	// if not attempted already, update the station definition in the search index
+	c.stationMu.Lock()
+	_, isUpdated := c.StationUpdated[s.Station.DataURL]
+	if !isUpdated && c.Search != nil {
+		c.StationUpdated[s.Station.DataURL] = true
+	}
+	c.stationMu.Unlock()
+	if !isUpdated && c.Search != nil {
+		span := c.Search.StoreStation(ctx, s.Station)
+		if err := span.Err(); err != nil {
+			// log the non-critical error but continue and do not prevent
+			// the forward flow. Note we are *not* logging the span telemetry
+			c.Log.Action("search.station.store", report.Data{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// search any of data URL, alias URL, or human URL to route to
+	// (using a map to remove dups between urls types)
+	urls := make(map[string]bool)
+	urls[s.Station.DataURL] = true
+	urls[s.Station.AliasURL] = true
+	urls[s.Station.HumanURL] = true
+	// pass the snapshot to the writer of each section (mailboxes never
+	// block, so a slow section does not hold up others)
+	var pending []chan error
+	for url := range urls {
+		c.routeMu.RLock()
+		mailboxes := c.SnapRoute[url]
+		c.routeMu.RUnlock()
+		for _, mb := range mailboxes {
+			done := make(chan error, 1)
+			c.inFlight.Add(1)
+			isCoalesced, dropped := mb.Put(Delivery{Snapshot: s, done: []chan error{done}})
+			if isCoalesced {
+				c.Log.Info("snapshot.coalesced", report.Data{
+					"data_url": s.Station.DataURL,
+				})
+			}
+			if dropped != nil {
+				// nacked for redelivery, either dropped from a full mailbox
+				// or rejected as the writer is being replaced
+				if dropped.Snapshot != s {
+					c.Log.Action("snapshot.dropped", report.Data{
+						"data_url": dropped.Snapshot.Station.DataURL,
+					})
+				}
+				c.ack(*dropped, errSnapshotDropped)
+			}
+			pending = append(pending, done)
+		}
+	}
+
+	// wait for each section to be stored, so a failure is redelivered
+	var firstErr error
+	for _, done := range pending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-done:
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	c.processed.add(s)
+
+	return nil
+}
+
+func findCalibrationForStation(calibrations []river.Calibration, station gauge.Station) (cal river.Calibration, exists bool) {
+	for _, c := range calibrations {
+		if c.URL == station.DataURL {
+			exists = true
+			cal = c
+		}
+		if c.URL == station.AliasURL {
+			exists = true
+			cal = c
+		}
+		if c.URL == station.HumanURL {
+			exists = true
+			cal = c
+		}
+	}
+	return
+}
@@ -0,0 +1,316 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/content"
+	"github.com/robtuley/rainchasers/internal/daemon"
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+)
+
+// failingStore fails to store the first nFailures records
+type failingStore struct {
+	*MemoryStore
+	mu        sync.Mutex
+	nFailures int
+	nStored   int
+}
+
+func (fs *failingStore) Store(ctx context.Context, record *Record) report.Span {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.nFailures > 0 {
+		fs.nFailures--
+		return report.StartSpan("failingstore.store").End(errors.New("unavailable"))
+	}
+	fs.nStored++
+	return fs.MemoryStore.Store(ctx, record)
+}
+
+func (fs *failingStore) stored() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.nStored
+}
+
+// slowStore blocks storing the record of one section until released
+type slowStore struct {
+	*MemoryStore
+	slowUUID string
+	release  chan struct{}
+}
+
+func (ss *slowStore) Store(ctx context.Context, record *Record) report.Span {
+	if record.Section.UUID == ss.slowUUID {
+		<-ss.release
+	}
+	return ss.MemoryStore.Store(ctx, record)
+}
+
+func testSnapshot(aliasURL string) *gauge.Snapshot {
+	return &gauge.Snapshot{
+		Station: gauge.Station{
+			DataURL:  aliasURL + "/10320",
+			AliasURL: aliasURL,
+			Type:     "level",
+		},
+		Readings:      []gauge.Reading{{EventTime: time.Now(), Value: 1.23}},
+		CorrelationID: "ABCDE",
+	}
+}
+
+func TestFailedSaveIsNackedAndRedelivered(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := daemon.New("test")
+	defer d.CloseWait()
+
+	rs := &failingStore{MemoryStore: NewMemoryStore(), nFailures: 1}
+	c := New(d.Logger)
+	c.Records = rs
+	section := river.Section{UUID: "e5d3f1a0-0000-4000-8000-000000000001"}
+	calibrations := []river.Calibration{{URL: "rloi://1234"}}
+	mb := NewMailbox(DefaultMailboxSize)
+	c.SnapRoute["rloi://1234"] = []*Mailbox{mb}
+	d.Run(ctx, c.CreateSnapshotsWriter(*NewRecord(section), calibrations, mb))
+
+	// nacked as the first save fails
+	snap := testSnapshot("rloi://1234")
+	if err := c.SnapshotRouter(ctx, nil, snap); err == nil {
+		t.Fatal("expected failed save to be nacked")
+	}
+	if n := rs.stored(); n != 0 {
+		t.Fatal("expected no store, got", n)
+	}
+
+	// and the redelivery (a duplicate of the unsaved record) is stored
+	// before it is acked
+	if err := c.SnapshotRouter(ctx, nil, snap); err != nil {
+		t.Fatal(err)
+	}
+	record, span := rs.Load(ctx, section.UUID)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || len(record.Measures) != 1 || len(record.Measures[0].Readings) != 1 {
+		t.Fatal("expected stored measure", record)
+	}
+
+	// a later duplicate delivery is not processed again
+	if err := c.SnapshotRouter(ctx, nil, snap); err != nil {
+		t.Fatal(err)
+	}
+	c.Settle()
+	if n := rs.stored(); n != 1 {
+		t.Error("expected a single store, got", n)
+	}
+	if n := d.Count("snapshot.duplicate"); n != 2 {
+		t.Error("expected 2 duplicates, got", n)
+	}
+}
+
+func TestDroppedDeliveryIsNacked(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := daemon.New("test")
+	defer d.CloseWait()
+
+	// a full mailbox with no writer to take from it
+	c := New(d.Logger)
+	c.Records = NewMemoryStore()
+	mb := NewMailbox(1)
+	c.SnapRoute["rloi://1"] = []*Mailbox{mb}
+	c.SnapRoute["rloi://2"] = []*Mailbox{mb}
+
+	routed := make(chan error, 1)
+	go func() {
+		routed <- c.SnapshotRouter(ctx, nil, testSnapshot("rloi://1"))
+	}()
+	for {
+		if depth, _, _ := mb.Stats(); depth == 1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("first snapshot not routed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	// another station drops the waiting delivery, which is nacked
+	go c.SnapshotRouter(ctx, nil, testSnapshot("rloi://2"))
+	select {
+	case err := <-routed:
+		if err != errSnapshotDropped {
+			t.Error("expected dropped snapshot to be nacked, got", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("dropped snapshot not nacked")
+	}
+	if n := d.Count("snapshot.dropped"); n != 1 {
+		t.Error("expected 1 dropped, got", n)
+	}
+}
+
+func TestRedeliveryAfterRestartIsSkipped(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := daemon.New("test")
+	defer d.CloseWait()
+
+	// a record saved before a restart, so the new cache has no processed keys
+	snap := testSnapshot("rloi://1234")
+	section := river.Section{UUID: "e5d3f1a0-0000-4000-8000-000000000001"}
+	calibrations := []river.Calibration{{URL: "rloi://1234"}}
+	record := NewRecord(section)
+	record.Measures = []Measure{{
+		Station:      snap.Station,
+		Calibration:  calibrations[0],
+		Readings:     snap.Readings,
+		LastSnapshot: processedKey(snap),
+	}}
+
+	rs := &failingStore{MemoryStore: NewMemoryStore()}
+	c := New(d.Logger)
+	c.Records = rs
+	mb := NewMailbox(DefaultMailboxSize)
+	c.SnapRoute["rloi://1234"] = []*Mailbox{mb}
+	d.Run(ctx, c.CreateSnapshotsWriter(*record, calibrations, mb))
+
+	if err := c.SnapshotRouter(ctx, nil, snap); err != nil {
+		t.Fatal(err)
+	}
+	c.Settle()
+	if n := rs.stored(); n != 0 {
+		t.Error("expected no store of redelivery, got", n)
+	}
+	if n := d.Count("snapshot.duplicate"); n != 1 {
+		t.Error("expected 1 duplicate, got", n)
+	}
+}
+
+func TestSlowSectionDoesNotDelayOtherStations(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := daemon.New("test")
+	defer d.CloseWait()
+
+	slow := river.Section{UUID: "e5d3f1a0-0000-4000-8000-00000000000a"}
+	fast := river.Section{UUID: "e5d3f1a0-0000-4000-8000-00000000000b"}
+	rs := &slowStore{MemoryStore: NewMemoryStore(), slowUUID: slow.UUID, release: make(chan struct{})}
+	c := New(d.Logger)
+	c.Records = rs
+	for _, s := range []struct {
+		section river.Section
+		url     string
+	}{{slow, "rloi://1"}, {fast, "rloi://2"}} {
+		mb := NewMailbox(DefaultMailboxSize)
+		c.SnapRoute[s.url] = []*Mailbox{mb}
+		calibrations := []river.Calibration{{URL: s.url}}
+		d.Run(ctx, c.CreateSnapshotsWriter(*NewRecord(s.section), calibrations, mb))
+	}
+
+	// the slow station waits to be stored, but routes concurrently with
+	// the second station which is stored and acked meanwhile
+	slowRouted := make(chan error, 1)
+	go func() {
+		slowRouted <- c.SnapshotRouter(ctx, nil, testSnapshot("rloi://1"))
+	}()
+	fastRouted := make(chan error, 1)
+	go func() {
+		fastRouted <- c.SnapshotRouter(ctx, nil, testSnapshot("rloi://2"))
+	}()
+	select {
+	case err := <-fastRouted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second station delayed by slow section")
+	}
+	record, _ := rs.MemoryStore.Load(ctx, fast.UUID)
+	if record == nil || len(record.Measures) != 1 {
+		t.Fatal("second station acked before stored", record)
+	}
+	select {
+	case <-slowRouted:
+		t.Fatal("slow station acked before stored")
+	default:
+	}
+
+	// until its record is stored
+	close(rs.release)
+	select {
+	case err := <-slowRouted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("slow station not acked once stored")
+	}
+	record, _ = rs.MemoryStore.Load(ctx, slow.UUID)
+	if record == nil || len(record.Measures) != 1 {
+		t.Error("slow station not stored", record)
+	}
+	c.Settle()
+}
+
+func TestApplyRebuildsRouting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d := daemon.New("test")
+	defer d.CloseWait()
+
+	c := New(d.Logger)
+	c.Records = NewMemoryStore()
+	a := river.Section{UUID: "e5d3f1a0-0000-4000-8000-00000000000a", Slug: "a"}
+	b := river.Section{UUID: "e5d3f1a0-0000-4000-8000-00000000000b", Slug: "b"}
+	cn := &content.Content{
+		Sections: []river.Section{a, b},
+		Calibrations: map[string][]river.Calibration{
+			a.UUID: {{URL: "rloi://1"}},
+			b.UUID: {{URL: "rloi://2"}},
+		},
+	}
+	if err := c.Apply(ctx, d, cn); err != nil {
+		t.Fatal(err)
+	}
+	before := c.SnapRoute
+
+	// recalibrate b to another gauge, and remove a
+	cn = &content.Content{
+		Sections: []river.Section{b},
+		Calibrations: map[string][]river.Calibration{
+			b.UUID: {{URL: "rloi://3"}},
+		},
+	}
+	if err := c.Apply(ctx, d, cn); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.SnapRoute) != 1 || len(c.SnapRoute["rloi://3"]) != 1 {
+		t.Fatal("unexpected routing", c.SnapRoute)
+	}
+
+	// the stopped writers reject anything routed to them
+	for _, url := range []string{"rloi://1", "rloi://2"} {
+		_, dropped := before[url][0].Put(delivery(url))
+		if dropped == nil {
+			t.Error("expected stopped writer to drop", url)
+		}
+	}
+
+	// an unchanged section keeps its writer
+	mb := c.SnapRoute["rloi://3"][0]
+	if err := c.Apply(ctx, d, cn); err != nil {
+		t.Fatal(err)
+	}
+	if c.SnapRoute["rloi://3"][0] != mb {
+		t.Error("expected unchanged section to keep its writer")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package store
 
 import (
 	"context"
@@ -11,7 +11,7 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// FireWriter handles firestore writes
+// FireWriter is a firestore record store
 type FireWriter struct {
 	Client     *firestore.Client
 	Collection *firestore.CollectionRef
@@ -50,38 +50,7 @@ func (fw *FireWriter) LoadAndUpdate(ctx context.Context, s river.Section) (hasCh
 	defer cancel()
 
 	span := report.StartSpan("firestore.loadandupdate").Field("uuid", s.UUID)
-
-	// get existing river data to check against
-	record, sp := fw.Load(ctx, s.UUID)
-	span = span.Child(sp)
-	if err := span.Err(); err != nil {
-		return false, record, span.End()
-	}
-
-	// if river has not changed, do nothing
-	if record != nil {
-		if checksum(s) == checksum(record.Section) {
-			return false, record, span.End()
-		}
-	}
-
-	// write new data with no snapshot gauge readings or state only if river
-	// definition has changed
-	new := Record{
-		Section: s,
-		Level: Level{
-			Label:  river.Unknown.String(),
-			Reason: "Not yet calibrated against nearby gauges",
-		},
-		Measures: make([]Measure, 0),
-	}
-	sp = fw.Store(ctx, &new)
-	span = span.Child(sp)
-	if err := span.Err(); err != nil {
-		return true, &new, span.End()
-	}
-
-	return true, &new, span.End()
+	return loadAndUpdate(ctx, fw, s, span)
 }
 
 // Load retrieves the latest river from firestore
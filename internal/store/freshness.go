@@ -0,0 +1,74 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+// FreshnessThresholds are how old the latest reading from each source can be
+// before a level is stale, allowing for the publish delay and polling cadence
+// of each source on top of the 15 minute reading interval
+var FreshnessThresholds = map[string]time.Duration{
+	"ea":   2 * time.Hour,
+	"nrw":  3 * time.Hour,
+	"sepa": 6 * time.Hour,
+	"":     6 * time.Hour, // unrecognised source
+}
+
+// freshnessCheckPeriod is how often levels are checked for staleness
+const freshnessCheckPeriod = 15 * time.Minute
+
+// Source identifies the gauge API a station is polled from
+func Source(s gauge.Station) string {
+	switch {
+	case strings.Contains(s.DataURL, "environment.data.gov.uk"):
+		return "ea"
+	case strings.Contains(s.DataURL, "sepa.org.uk"):
+		return "sepa"
+	case strings.HasPrefix(s.DataURL, "rloi://"):
+		return "nrw"
+	}
+	return ""
+}
+
+// freshnessThreshold is the maximum age of a fresh reading from the station
+func freshnessThreshold(s gauge.Station) time.Duration {
+	if d, ok := FreshnessThresholds[Source(s)]; ok {
+		return d
+	}
+	return FreshnessThresholds[""]
+}
+
+// CurrentLevel returns the latest level as of now, which is an unknown
+// and stale level if the latest reading is older than the station threshold
+func (m Measure) CurrentLevel(now time.Time, window time.Duration) Level {
+	l := m.LatestLevelWithTrend(window)
+	if l.EventTime.IsZero() {
+		return l
+	}
+
+	age := now.Sub(l.EventTime)
+	if age <= freshnessThreshold(m.Station) {
+		return l
+	}
+	l.Label = river.Unknown.String()
+	l.Reason += " is stale (" + describeAge(age) + " old)"
+	l.Trend = TrendUnknown
+	l.RatePerHour = 0
+	l.Stale = true
+	return l
+}
+
+func describeAge(age time.Duration) string {
+	switch {
+	case age < 2*time.Hour:
+		return strconv.Itoa(int(age/time.Minute)) + " minutes"
+	case age < 48*time.Hour:
+		return strconv.Itoa(int(age/time.Hour)) + " hours"
+	}
+	return strconv.Itoa(int(age/(24*time.Hour))) + " days"
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+func TestSourceOfStation(t *testing.T) {
+	testCases := map[string]string{
+		"http://environment.data.gov.uk/flood-monitoring/id/measures/1029TH-level-stage-i-15_min-mASD": "ea",
+		"https://www2.sepa.org.uk/waterlevels/CSVs/133148-SG.csv":                                      "sepa",
+		"rloi://4129":              "nrw",
+		"http://example.com/gauge": "",
+	}
+	for url, expected := range testCases {
+		if s := Source(gauge.Station{DataURL: url}); s != expected {
+			t.Error(url, "expected", expected, "got", s)
+		}
+	}
+}
+
+func TestStaleLevels(t *testing.T) {
+	now := time.Date(2020, 10, 6, 12, 0, 0, 0, time.UTC)
+	cals := []river.Calibration{
+		{URL: "rloi://1", Minimum: map[string]float32{"low": 1, "medium": 2}},
+		{URL: "sepa://2", Minimum: map[string]float32{"low": 1, "medium": 2}},
+	}
+	nrw := Measure{
+		Station:     gauge.Station{DataURL: "rloi://1", AliasURL: "rloi://1", Name: "Pont"},
+		Calibration: cals[0],
+		Readings:    []gauge.Reading{{EventTime: now.Add(-4 * time.Hour), Value: 2.5}},
+	}
+	sepa := Measure{
+		Station:     gauge.Station{DataURL: "https://www2.sepa.org.uk/waterlevels/CSVs/2-SG.csv", AliasURL: "sepa://2", Name: "Bridge"},
+		Calibration: cals[1],
+		Readings:    []gauge.Reading{{EventTime: now.Add(-4 * time.Hour), Value: 1.5}},
+	}
+
+	// 4 hours is stale for NRW but not for SEPA
+	l := nrw.CurrentLevel(now, DefaultTrendWindow)
+	if !l.Stale || l.Label != "unknown" || l.Reason != "2.50 at Pont is stale (4 hours old)" {
+		t.Error("expected stale level", l)
+	}
+	if l := sepa.CurrentLevel(now, DefaultTrendWindow); l.Stale || l.Label != "low" {
+		t.Error("expected fresh level", l)
+	}
+
+	// a stale gauge falls back to the next
+	l = resolveLevel(river.Priority, cals, []Measure{nrw, sepa}, now, DefaultTrendWindow)
+	if l.Stale || l.Label != "low" || l.Reason != "1.50 at Bridge (as Pont unavailable)" {
+		t.Error("expected fallback level", l)
+	}
+
+	// until all gauges are stale
+	l = resolveLevel(river.Priority, cals, []Measure{nrw, sepa}, now.Add(3*time.Hour), DefaultTrendWindow)
+	if !l.Stale || l.Label != "unknown" || l.Reason != "2.50 at Pont is stale (7 hours old), 1.50 at Bridge is stale (7 hours old)" {
+		t.Error("expected stale level", l)
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// DefaultMailboxSize is how many stations can wait for a section writer
+const DefaultMailboxSize = 32
+
+// DefaultMaxLag is how long a snapshot can wait for a section writer before
+// the section is logged as lagging
+const DefaultMaxLag = time.Minute
+
+// flushTimeout limits saving the snapshots waiting for a stopped writer
+const flushTimeout = 10 * time.Second
+
+// errSnapshotDropped nacks a snapshot dropped from a full mailbox, or routed
+// to the closed mailbox of a stopped writer, so the message is redelivered
+var errSnapshotDropped = errors.New("snapshot dropped by section mailbox")
+
+// Delivery is a snapshot routed to a section writer, which sends the result
+// of storing it on each done channel so the messages are only acked once
+// stored
+//
+// A delivery coalesces every snapshot of a station that arrived while the
+// writer was busy, so has a done channel for each of their messages.
+type Delivery struct {
+	Snapshot *gauge.Snapshot
+
+	done     []chan error
+	queuedAt time.Time
+}
+
+// Mailbox is a buffered queue of deliveries to a section writer that never
+// blocks the router
+//
+// When the writer falls behind, a snapshot of a station that is already
+// waiting is coalesced into it: the newest station details are kept and the
+// readings of both are merged, so the backlog is at most one delivery per
+// station. If the mailbox is still full the oldest delivery is dropped and
+// nacked so the broker redelivers its messages later.
+type Mailbox struct {
+	size  int
+	ready chan struct{}
+
+	mu         sync.Mutex
+	isClosed   bool
+	pending    []Delivery
+	nCoalesced int
+	nDropped   int
+}
+
+// NewMailbox creates an empty mailbox that holds up to size deliveries
+func NewMailbox(size int) *Mailbox {
+	return &Mailbox{
+		size:  size,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Ready is signalled when a delivery is waiting to be taken
+func (mb *Mailbox) Ready() <-chan struct{} {
+	return mb.ready
+}
+
+// Put adds a delivery without blocking, returning true if it was coalesced
+// with a waiting delivery of the same station, and any delivery dropped to
+// make room (or the delivery itself if the mailbox is closed)
+func (mb *Mailbox) Put(d Delivery) (isCoalesced bool, dropped *Delivery) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	defer mb.signal()
+
+	if mb.isClosed {
+		mb.nDropped++
+		return false, &d
+	}
+
+	if d.queuedAt.IsZero() {
+		d.queuedAt = time.Now()
+	}
+
+	for i, p := range mb.pending {
+		if p.Snapshot.Station.DataURL != d.Snapshot.Station.DataURL {
+			continue
+		}
+		// copy the newer snapshot as it is shared with other sections, and
+		// merge it first so its readings are kept at equal quality
+		s := *d.Snapshot
+		s.Readings = merge(append([]gauge.Reading{}, d.Snapshot.Readings...), p.Snapshot.Readings)
+		mb.pending[i] = Delivery{
+			Snapshot: &s,
+			done:     append(p.done, d.done...),
+			queuedAt: p.queuedAt,
+		}
+		mb.nCoalesced++
+		return true, nil
+	}
+
+	if len(mb.pending) >= mb.size {
+		oldest := mb.pending[0]
+		mb.pending = mb.pending[1:]
+		mb.nDropped++
+		dropped = &oldest
+	}
+	mb.pending = append(mb.pending, d)
+	return false, dropped
+}
+
+// Take removes the oldest delivery, false if there is none
+func (mb *Mailbox) Take() (Delivery, bool) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	defer mb.signal()
+
+	if len(mb.pending) == 0 {
+		return Delivery{}, false
+	}
+	d := mb.pending[0]
+	mb.pending = mb.pending[1:]
+	return d, true
+}
+
+// Close stops further deliveries, returning those still waiting
+func (mb *Mailbox) Close() []Delivery {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.isClosed = true
+	pending := mb.pending
+	mb.pending = nil
+	return pending
+}
+
+// signal marks the mailbox ready if there are deliveries waiting
+func (mb *Mailbox) signal() {
+	if len(mb.pending) == 0 {
+		return
+	}
+	select {
+	case mb.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Stats provides the current queue depth, and the total deliveries that
+// have been coalesced or dropped
+func (mb *Mailbox) Stats() (depth int, nCoalesced int, nDropped int) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return len(mb.pending), mb.nCoalesced, mb.nDropped
+}
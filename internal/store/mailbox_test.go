@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func delivery(dataURL string, readings ...gauge.Reading) Delivery {
+	return Delivery{
+		Snapshot: &gauge.Snapshot{
+			Station:  gauge.Station{DataURL: dataURL},
+			Readings: readings,
+		},
+		done: []chan error{make(chan error, 1)},
+	}
+}
+
+func TestMailboxCoalescesStation(t *testing.T) {
+	t0 := time.Date(2020, 10, 6, 20, 0, 0, 0, time.UTC)
+	r0 := gauge.Reading{EventTime: t0, Value: 1.0}
+	r1 := gauge.Reading{EventTime: t0.Add(15 * time.Minute), Value: 1.1}
+
+	mb := NewMailbox(DefaultMailboxSize)
+	first := delivery("http://example.com/a", r0)
+	if isCoalesced, _ := mb.Put(first); isCoalesced {
+		t.Error("unexpected coalesce of first delivery")
+	}
+	mb.Put(delivery("http://example.com/b", r0))
+	if isCoalesced, _ := mb.Put(delivery("http://example.com/a", r1)); !isCoalesced {
+		t.Error("expected coalesce of same station")
+	}
+
+	depth, nCoalesced, nDropped := mb.Stats()
+	if depth != 2 || nCoalesced != 1 || nDropped != 0 {
+		t.Error("unexpected stats", depth, nCoalesced, nDropped)
+	}
+
+	select {
+	case <-mb.Ready():
+	default:
+		t.Fatal("expected mailbox ready")
+	}
+	d, ok := mb.Take()
+	if !ok || d.Snapshot.Station.DataURL != "http://example.com/a" {
+		t.Fatal("expected coalesced station first", d)
+	}
+	if len(d.Snapshot.Readings) != 2 || len(d.done) != 2 {
+		t.Error("expected readings and done channels of both snapshots", d)
+	}
+	if len(first.Snapshot.Readings) != 1 {
+		t.Error("coalesce modified the original snapshot", first.Snapshot)
+	}
+
+	// still ready for the remaining delivery
+	select {
+	case <-mb.Ready():
+	default:
+		t.Fatal("expected mailbox still ready")
+	}
+	if d, ok := mb.Take(); !ok || d.Snapshot.Station.DataURL != "http://example.com/b" {
+		t.Error("expected second station", d)
+	}
+	if _, ok := mb.Take(); ok {
+		t.Error("expected empty mailbox")
+	}
+}
+
+func TestMailboxDropsOldestWhenFull(t *testing.T) {
+	mb := NewMailbox(2)
+	mb.Put(delivery("http://example.com/a"))
+	mb.Put(delivery("http://example.com/b"))
+	_, dropped := mb.Put(delivery("http://example.com/c"))
+	if dropped == nil || dropped.Snapshot.Station.DataURL != "http://example.com/a" {
+		t.Fatal("expected oldest delivery dropped", dropped)
+	}
+	if depth, _, nDropped := mb.Stats(); depth != 2 || nDropped != 1 {
+		t.Error("unexpected stats", depth, nDropped)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package store
 
 import (
 	"sort"
@@ -30,12 +30,39 @@ type Record struct {
 	Measures []Measure     `firestore:"measures"`
 }
 
+// NewRecord creates a record with no snapshot gauge readings or state
+func NewRecord(s river.Section) *Record {
+	return &Record{
+		Section: s,
+		Level: Level{
+			Label:  river.Unknown.String(),
+			Reason: "Not yet calibrated against nearby gauges",
+			Trend:  TrendUnknown,
+		},
+		Measures: make([]Measure, 0),
+	}
+}
+
+// copy duplicates the record so it can be shared outside of its writer
+func (r Record) copy() Record {
+	measures := make([]Measure, len(r.Measures))
+	for i, m := range r.Measures {
+		m.Readings = append([]gauge.Reading(nil), m.Readings...)
+		measures[i] = m
+	}
+	r.Measures = measures
+	return r
+}
+
 // Level is the calculated river current level state
 type Level struct {
 	EventTime     time.Time `firestore:"event_time"`     // time when this was true
 	ProcessedTime time.Time `firestore:"processed_time"` // time when this was processed
 	Label         string    `firestore:"label"`          // e.g. "high"
 	Reason        string    `firestore:"reason"`         // e.g. "0.98 at Hafod Wydr gauge"
+	Trend         string    `firestore:"trend"`          // e.g. "rising"
+	RatePerHour   float32   `firestore:"rate_per_hour"`  // change in value per hour
+	Stale         bool      `firestore:"stale"`          // unknown as latest reading is too old
 }
 
 // Measure is a relevant river measurement time series
@@ -44,28 +71,40 @@ type Measure struct {
 	Calibration   river.Calibration `firestore:"calibration"`
 	Readings      []gauge.Reading   `firestore:"readings"`
 	ProcessedTime time.Time         `firestore:"processed_time"`
+	LastSnapshot  string            `firestore:"last_snapshot"` // correlation ID and data URL of the last saved
 }
 
 // LatestLevel returns the latest level calibration if available
 func (m Measure) LatestLevel() Level {
+	return m.LatestLevelWithTrend(DefaultTrendWindow)
+}
+
+// LatestLevelWithTrend returns the latest level with the trend over window
+func (m Measure) LatestLevelWithTrend(window time.Duration) Level {
 	if len(m.Readings) == 0 {
 		return Level{
 			Label:  river.Unknown.String(),
 			Reason: "No readings currently available from " + m.Station.Name,
+			Trend:  TrendUnknown,
 		}
 	}
 
 	// readings are always sorted by most recent first by convention
 	r := m.Readings[0]
 	v := strconv.FormatFloat(float64(r.Value), 'f', 2, 32)
+	trend, rate := trend(m.Readings, window)
 	return Level{
 		EventTime:     r.EventTime,
 		ProcessedTime: m.ProcessedTime,
 		Label:         m.Calibration.LevelAt(r.Value).String(),
 		Reason:        v + " at " + m.Station.Name,
+		Trend:         trend,
+		RatePerHour:   rate,
 	}
 }
 
+// merge combines two sets of readings, where a reading of higher quality
+// replaces one at the same time, otherwise the first is kept
 func merge(a []gauge.Reading, b []gauge.Reading) []gauge.Reading {
 	concat := append(a, b...)
 	removeDuplicates(&concat)
@@ -74,14 +113,18 @@ func merge(a []gauge.Reading, b []gauge.Reading) []gauge.Reading {
 }
 
 func removeDuplicates(xs *[]gauge.Reading) {
-	found := make(map[time.Time]bool)
+	found := make(map[time.Time]int)
 	j := 0
 	for i, x := range *xs {
-		if !found[x.EventTime] {
-			found[x.EventTime] = true
-			(*xs)[j] = (*xs)[i]
-			j++
+		if k, ok := found[x.EventTime]; ok {
+			if x.Outranks((*xs)[k]) {
+				(*xs)[k] = x
+			}
+			continue
 		}
+		found[x.EventTime] = j
+		(*xs)[j] = (*xs)[i]
+		j++
 	}
 	*xs = (*xs)[:j]
 }
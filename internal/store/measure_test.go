@@ -1,4 +1,4 @@
-package main
+package store
 
 import (
 	"reflect"
@@ -51,6 +51,60 @@ func TestMergeDeDuplicatesAndOrdersByTime(t *testing.T) {
 	}
 }
 
+func TestMergePrefersHigherQuality(t *testing.T) {
+	timestamp, _ := time.Parse(time.RFC3339, "2016-01-01T10:30:00Z")
+	provisional := gauge.Reading{
+		EventTime: timestamp,
+		Value:     1.23,
+		Quality:   gauge.QualityProvisional,
+		Source:    "ea-recent",
+	}
+	validated := gauge.Reading{
+		EventTime: timestamp,
+		Value:     1.25,
+		Quality:   gauge.QualityValidated,
+		Source:    "ea-hydrology",
+	}
+	suspect := gauge.Reading{
+		EventTime: timestamp,
+		Value:     9.99,
+		Quality:   gauge.QualitySuspect,
+	}
+
+	result := merge([]gauge.Reading{provisional}, []gauge.Reading{validated})
+	if !reflect.DeepEqual(result, []gauge.Reading{validated}) {
+		t.Error("merge() did not upgrade quality", result)
+	}
+
+	result = merge([]gauge.Reading{validated}, []gauge.Reading{provisional, suspect})
+	if !reflect.DeepEqual(result, []gauge.Reading{validated}) {
+		t.Error("merge() downgraded quality", result)
+	}
+
+	// a checked estimate backfills provisional telemetry, but not validated
+	estimated := gauge.Reading{
+		EventTime: timestamp,
+		Value:     1.24,
+		Quality:   gauge.QualityEstimated,
+		Source:    "ea-hydrology",
+	}
+	result = merge([]gauge.Reading{provisional}, []gauge.Reading{estimated})
+	if !reflect.DeepEqual(result, []gauge.Reading{estimated}) {
+		t.Error("merge() did not replace provisional with estimated", result)
+	}
+	result = merge([]gauge.Reading{validated}, []gauge.Reading{estimated})
+	if !reflect.DeepEqual(result, []gauge.Reading{validated}) {
+		t.Error("merge() replaced validated with estimated", result)
+	}
+
+	// unknown quality (older messages) is preferred to suspect
+	unknown := gauge.Reading{EventTime: timestamp, Value: 1.24}
+	result = merge([]gauge.Reading{suspect}, []gauge.Reading{unknown})
+	if !reflect.DeepEqual(result, []gauge.Reading{unknown}) {
+		t.Error("merge() kept suspect reading", result)
+	}
+}
+
 func TestRemoveOlderThan(t *testing.T) {
 	timestamp, _ := time.Parse(time.RFC3339, "2016-01-01T10:30:00Z")
 	r1 := gauge.Reading{
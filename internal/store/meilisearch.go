@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+// MeiliIndexer is a search indexer for a self-hosted Meilisearch server
+//
+// Rivers are searchable by river & section name, filterable by grade and
+// level, and stations by name and river. Both can be filtered by distance
+// with _geoRadius(lat, lng, metres).
+type MeiliIndexer struct {
+	URL          string
+	APIKey       string
+	RiverIndex   string
+	StationIndex string
+	Client       *http.Client
+}
+
+// NewMeiliIndexer creates a Meilisearch indexer and configures its indexes
+func NewMeiliIndexer(ctx context.Context, url string, apiKey string) (*MeiliIndexer, report.Span) {
+	span := report.StartSpan("meilisearch.connect").Field("url", url)
+
+	mi := &MeiliIndexer{
+		URL:          strings.TrimRight(url, "/"),
+		APIKey:       apiKey,
+		RiverIndex:   "rivers",
+		StationIndex: "stations",
+		Client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	err := mi.request(ctx, http.MethodPatch, "/indexes/"+mi.RiverIndex+"/settings", map[string]interface{}{
+		"searchableAttributes": []string{"river", "section", "grade", "desc"},
+		"filterableAttributes": []string{"grade_numeric", "level_label", "level_trend", "level_stale", "_geo"},
+		"sortableAttributes":   []string{"grade_numeric", "_geo"},
+	})
+	if err != nil {
+		return nil, span.End(err)
+	}
+
+	err = mi.request(ctx, http.MethodPatch, "/indexes/"+mi.StationIndex+"/settings", map[string]interface{}{
+		"searchableAttributes": []string{"name", "name_cy", "river"},
+		"filterableAttributes": []string{"type", "_geo"},
+		"sortableAttributes":   []string{"_geo"},
+	})
+	if err != nil {
+		return nil, span.End(err)
+	}
+
+	return mi, span.End()
+}
+
+// StoreRecord saves a river record to meilisearch
+func (mi *MeiliIndexer) StoreRecord(ctx context.Context, record *Record) report.Span {
+	uuid := record.Section.UUID
+	span := report.StartSpan("meilisearch.store").Field("uuid", uuid)
+
+	doc := recordFields(record)
+	doc["id"] = uuid
+	doc["_geo"] = map[string]float32{
+		"lat": record.Section.Putin.Lat,
+		"lng": record.Section.Putin.Lng,
+	}
+	err := mi.addDocument(ctx, mi.RiverIndex, doc)
+	return span.End(err)
+}
+
+// StoreStation saves a station to meilisearch
+func (mi *MeiliIndexer) StoreStation(ctx context.Context, station gauge.Station) report.Span {
+	span := report.StartSpan("meilisearch.store").Field("data_url", station.DataURL)
+
+	// document IDs are restricted to alphanumeric, - and _ characters
+	doc := stationFields(station)
+	doc["id"] = checksum(station.DataURL)
+	doc["data_url"] = station.DataURL
+	doc["_geo"] = map[string]float32{
+		"lat": station.Lat,
+		"lng": station.Lg,
+	}
+	err := mi.addDocument(ctx, mi.StationIndex, doc)
+	return span.End(err)
+}
+
+func (mi *MeiliIndexer) addDocument(ctx context.Context, index string, doc map[string]interface{}) error {
+	path := "/indexes/" + index + "/documents?primaryKey=id"
+	return mi.request(ctx, http.MethodPut, path, []map[string]interface{}{doc})
+}
+
+// request sends a JSON body and expects an accepted or successful response
+func (mi *MeiliIndexer) request(ctx context.Context, method string, path string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, mi.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if mi.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+mi.APIKey)
+	}
+
+	resp, err := mi.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New("meilisearch " + method + " " + path + " status " +
+			strconv.Itoa(resp.StatusCode) + ": " + string(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+func TestMeiliIndexer(t *testing.T) {
+	docs := make(map[string][]map[string]interface{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPut {
+			var body []map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Error(err)
+			}
+			docs[r.URL.Path] = append(docs[r.URL.Path], body...)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	mi, span := NewMeiliIndexer(ctx, ts.URL, "key")
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	record := NewRecord(river.Section{
+		UUID:      "aaf61b44-1a20-4f3c-9a97-66b3e6cbc0a5",
+		RiverName: "Tees",
+		Grade:     river.Grade{Human: "3(4)", Average: 3},
+		Putin:     river.LatLng{Lat: 54.6, Lng: -2.1},
+	})
+	if err := mi.StoreRecord(ctx, record).Err(); err != nil {
+		t.Fatal(err)
+	}
+	rivers := docs["/indexes/rivers/documents"]
+	if len(rivers) != 1 || rivers[0]["id"] != record.Section.UUID || rivers[0]["river"] != "Tees" {
+		t.Fatal("unexpected river documents", rivers)
+	}
+	if geo, ok := rivers[0]["_geo"].(map[string]interface{}); !ok || geo["lng"] == nil {
+		t.Error("expected geo location", rivers[0]["_geo"])
+	}
+
+	station := gauge.Station{DataURL: "http://example.com/station/1", Name: "Barnard Castle"}
+	if err := mi.StoreStation(ctx, station).Err(); err != nil {
+		t.Fatal(err)
+	}
+	stations := docs["/indexes/stations/documents"]
+	if len(stations) != 1 || stations[0]["data_url"] != station.DataURL {
+		t.Fatal("unexpected station documents", stations)
+	}
+
+	mi.APIKey = "wrong"
+	if err := mi.StoreStation(ctx, station).Err(); err == nil {
+		t.Error("expected error from unauthorized request")
+	}
+}
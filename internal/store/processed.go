@@ -0,0 +1,68 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// processedSize is how many recently stored snapshots are remembered
+const processedSize = 10000
+
+// processed remembers recently stored snapshots so a redelivered message is
+// not processed twice
+//
+// Snapshots are keyed on correlation ID plus data URL, as each snapshot of
+// a batch shares the correlation ID of the batch. The oldest keys are
+// forgotten first once full, and all of them on restart, so each measure
+// also saves the key of its last snapshot. Beyond that a duplicate is
+// stored again, so store writes must stay idempotent: readings are merged
+// by time and history is keyed by time.
+type processed struct {
+	mu    sync.Mutex
+	keys  map[string]bool
+	order []string
+	next  int
+}
+
+func newProcessed(size int) *processed {
+	return &processed{
+		keys:  make(map[string]bool, size),
+		order: make([]string, size),
+	}
+}
+
+func processedKey(s *gauge.Snapshot) string {
+	if s.CorrelationID == "" {
+		return ""
+	}
+	return s.CorrelationID + " " + s.Station.DataURL
+}
+
+// has is true if the snapshot has already been stored
+func (p *processed) has(s *gauge.Snapshot) bool {
+	key := processedKey(s)
+	if key == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.keys[key]
+}
+
+// add records the snapshot has been stored
+func (p *processed) add(s *gauge.Snapshot) {
+	key := processedKey(s)
+	if key == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys[key] {
+		return
+	}
+	delete(p.keys, p.order[p.next])
+	p.order[p.next] = key
+	p.keys[key] = true
+	p.next = (p.next + 1) % len(p.order)
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func TestProcessedForgetsOldest(t *testing.T) {
+	snapshot := func(i int) *gauge.Snapshot {
+		return &gauge.Snapshot{
+			Station:       gauge.Station{DataURL: "http://example.com/data"},
+			CorrelationID: strconv.Itoa(i),
+		}
+	}
+
+	p := newProcessed(2)
+	p.add(snapshot(1))
+	p.add(snapshot(2))
+	if !p.has(snapshot(1)) || !p.has(snapshot(2)) {
+		t.Error("expected both snapshots processed")
+	}
+	p.add(snapshot(3))
+	if p.has(snapshot(1)) {
+		t.Error("expected oldest snapshot forgotten")
+	}
+	if !p.has(snapshot(2)) || !p.has(snapshot(3)) {
+		t.Error("expected newest snapshots processed")
+	}
+
+	// without a correlation ID there is nothing to key on
+	untraced := &gauge.Snapshot{}
+	p.add(untraced)
+	if p.has(untraced) {
+		t.Error("unexpected untraced snapshot processed")
+	}
+}
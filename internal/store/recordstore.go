@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+)
+
+// RecordStore persists river records
+//
+// Load returns a nil record with no span error if the record does not exist.
+type RecordStore interface {
+	Load(ctx context.Context, uuid string) (*Record, report.Span)
+	Store(ctx context.Context, record *Record) report.Span
+	LoadAndUpdate(ctx context.Context, s river.Section) (hasChanged bool, record *Record, traceSpan report.Span)
+	Close()
+}
+
+// OpenRecordStore connects to a bbolt file if boltPath is set, otherwise
+// firestore if projectID is set, otherwise an empty in-memory store
+func OpenRecordStore(boltPath string, projectID string) (RecordStore, report.Span) {
+	if boltPath != "" {
+		return NewBoltStore(boltPath)
+	}
+	if projectID != "" {
+		return NewFireWriter(projectID)
+	}
+	return NewMemoryStore(), report.StartSpan("memorystore.connect").End()
+}
+
+// loadAndUpdate resets a record if the section definition has changed
+func loadAndUpdate(ctx context.Context, rs RecordStore, s river.Section, span report.Span) (bool, *Record, report.Span) {
+	// get existing river data to check against
+	record, sp := rs.Load(ctx, s.UUID)
+	span = span.Child(sp)
+	if err := span.Err(); err != nil {
+		return false, record, span.End()
+	}
+
+	// if river has not changed, do nothing
+	if record != nil {
+		if checksum(s) == checksum(record.Section) {
+			return false, record, span.End()
+		}
+	}
+
+	// write new data with no snapshot gauge readings or state only if river
+	// definition has changed
+	new := NewRecord(s)
+	sp = rs.Store(ctx, new)
+	span = span.Child(sp)
+	return true, new, span.End()
+}
+
+// MemoryStore keeps records in memory only
+type MemoryStore struct {
+	mutex   sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore creates an empty in-memory record store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+	}
+}
+
+// Close releases any resources
+func (ms *MemoryStore) Close() {}
+
+// LoadAndUpdate resets the record if the section has changed
+func (ms *MemoryStore) LoadAndUpdate(ctx context.Context, s river.Section) (bool, *Record, report.Span) {
+	span := report.StartSpan("memory.loadandupdate").Field("uuid", s.UUID)
+	return loadAndUpdate(ctx, ms, s, span)
+}
+
+// Load retrieves a copy of the latest river record
+func (ms *MemoryStore) Load(ctx context.Context, uuid string) (*Record, report.Span) {
+	span := report.StartSpan("memory.load").Field("uuid", uuid)
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	record, ok := ms.records[uuid]
+	if !ok {
+		return nil, span.End()
+	}
+	record = record.copy()
+	return &record, span.End()
+}
+
+// Store saves a copy of the river record
+func (ms *MemoryStore) Store(ctx context.Context, record *Record) report.Span {
+	span := report.StartSpan("memory.store").Field("uuid", record.Section.UUID)
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.records[record.Section.UUID] = record.copy()
+	return span.End()
+}
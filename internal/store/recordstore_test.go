@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testRecordStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	bs, span := NewBoltStore(filepath.Join(t.TempDir(), "records.db"))
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	testRecordStore(t, bs)
+}
+
+func TestRemoteStoreLoadsServedRecords(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	s := river.Section{UUID: "aaf61b44-1a20-4f3c-9a97-66b3e6cbc0a5"}
+	if _, _, span := ms.LoadAndUpdate(ctx, s); span.Err() != nil {
+		t.Fatal(span.Err())
+	}
+
+	ts := httptest.NewServer(RecordHandler(ms))
+	defer ts.Close()
+	rs, span := NewRemoteStore(ts.URL)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	r, span := rs.Load(ctx, s.UUID)
+	if err := span.Err(); err != nil || r == nil || r.Section.UUID != s.UUID {
+		t.Fatal("expected served record", r, err)
+	}
+	r, span = rs.Load(ctx, "unknown")
+	if err := span.Err(); err != nil || r != nil {
+		t.Error("expected no record", r, err)
+	}
+	if err := rs.Store(ctx, NewRecord(s)).Err(); err == nil {
+		t.Error("expected remote store to be read only")
+	}
+}
+
+func testRecordStore(t *testing.T, rs RecordStore) {
+	ctx := context.Background()
+	defer rs.Close()
+
+	s := river.Section{
+		UUID:        "aaf61b44-1a20-4f3c-9a97-66b3e6cbc0a5",
+		Slug:        "test-section",
+		SectionName: "Upper",
+	}
+
+	r, span := rs.Load(ctx, s.UUID)
+	if err := span.Err(); err != nil || r != nil {
+		t.Fatal("expected no record before store", r, err)
+	}
+
+	hasChanged, r, span := rs.LoadAndUpdate(ctx, s)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasChanged || r.Section.UUID != s.UUID {
+		t.Error("expected new record to be stored", hasChanged, r)
+	}
+
+	// store readings against the record
+	eventTime := time.Date(2020, 10, 6, 22, 0, 0, 0, time.UTC)
+	r.Measures = append(r.Measures, Measure{
+		Station:  gauge.Station{DataURL: "http://example.com/station"},
+		Readings: []gauge.Reading{{EventTime: eventTime, Value: 1.23}},
+	})
+	if err := rs.Store(ctx, r).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// unchanged section retains the readings
+	hasChanged, r, span = rs.LoadAndUpdate(ctx, s)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if hasChanged {
+		t.Error("expected unchanged section")
+	}
+	if len(r.Measures) != 1 || len(r.Measures[0].Readings) != 1 {
+		t.Fatal("expected stored readings", r.Measures)
+	}
+	if !r.Measures[0].Readings[0].EventTime.Equal(eventTime) {
+		t.Error("reading time mis-match", r.Measures[0].Readings[0].EventTime)
+	}
+
+	// a changed section is reset
+	s.SectionName = "Lower"
+	hasChanged, r, span = rs.LoadAndUpdate(ctx, s)
+	if err := span.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !hasChanged || len(r.Measures) != 0 {
+		t.Error("expected changed section to be reset", hasChanged, r.Measures)
+	}
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/river"
+	"github.com/robtuley/report"
+)
+
+var errRemoteReadOnly = errors.New("remote record store is read only")
+
+// RecordHandler serves each record as JSON below /records/{uuid} for a
+// RemoteStore, from the process that holds the record store open
+func RecordHandler(rs RecordStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uuid := strings.TrimPrefix(r.URL.Path, "/records/")
+		record, span := rs.Load(r.Context(), uuid)
+		if err := span.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if record == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(record)
+	})
+}
+
+// RemoteStore loads the records served by RecordHandler in another process
+// (e.g. a store daemon with a bbolt file) and cannot store them
+type RemoteStore struct {
+	URL    string // base URL the handler is served below, e.g. http://store:8081
+	Client *http.Client
+}
+
+// NewRemoteStore creates a client of the records served below baseURL
+func NewRemoteStore(baseURL string) (*RemoteStore, report.Span) {
+	span := report.StartSpan("remotestore.connect").Field("url", baseURL)
+	return &RemoteStore{
+		URL:    strings.TrimSuffix(baseURL, "/"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}, span.End()
+}
+
+// Close releases any resources
+func (rs *RemoteStore) Close() {}
+
+// LoadAndUpdate is not supported as the store is read only
+func (rs *RemoteStore) LoadAndUpdate(ctx context.Context, s river.Section) (bool, *Record, report.Span) {
+	span := report.StartSpan("remotestore.loadandupdate").Field("uuid", s.UUID)
+	return false, nil, span.End(errRemoteReadOnly)
+}
+
+// Load retrieves the latest river record from the serving process
+func (rs *RemoteStore) Load(ctx context.Context, uuid string) (*Record, report.Span) {
+	span := report.StartSpan("remotestore.load").Field("uuid", uuid)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rs.URL+"/records/"+url.PathEscape(uuid), nil)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	resp, err := rs.Client.Do(req)
+	if err != nil {
+		return nil, span.End(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, span.End()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, span.End(errors.New("records responded " + resp.Status))
+	}
+
+	var record Record
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return nil, span.End(err)
+	}
+	return &record, span.End()
+}
+
+// Store is not supported as the store is read only
+func (rs *RemoteStore) Store(ctx context.Context, record *Record) report.Span {
+	span := report.StartSpan("remotestore.store").Field("uuid", record.Section.UUID)
+	return span.End(errRemoteReadOnly)
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+// contribution is the level of a single gauge towards a section level
+type contribution struct {
+	order  int
+	weight float32
+	name   string
+	level  Level
+}
+
+// resolveLevel combines the latest level of each measure into a section level
+//
+// Measures are considered in calibration order (by priority, then as listed),
+// and only those with a known level contribute. The reason explains which
+// gauges contributed when there is more than one.
+func resolveLevel(strategy river.Strategy, calibrations []river.Calibration, measures []Measure, now time.Time, window time.Duration) Level {
+	// rank calibrations by priority, keeping listed order for equal priority
+	sorted := append([]river.Calibration(nil), calibrations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	order := make(map[string]int, len(sorted))
+	for i, c := range sorted {
+		order[c.URL] = i
+	}
+
+	var known, unknown []contribution
+	for _, m := range measures {
+		c := contribution{
+			order:  len(sorted),
+			weight: m.Calibration.Weight,
+			name:   m.Station.Name,
+			level:  m.CurrentLevel(now, window),
+		}
+		if i, ok := order[m.Calibration.URL]; ok {
+			c.order = i
+		}
+		if c.weight <= 0 {
+			c.weight = 1
+		}
+		if c.level.Label == river.Unknown.String() {
+			unknown = append(unknown, c)
+		} else {
+			known = append(known, c)
+		}
+	}
+	sortByOrder(known)
+	sortByOrder(unknown)
+
+	switch {
+	case len(measures) == 0:
+		return NewRecord(river.Section{}).Level
+	case len(measures) == 1 && len(known) == 1:
+		return known[0].level
+	case len(measures) == 1:
+		return unknown[0].level
+	case len(known) == 0:
+		// stale readings are a better explanation than no readings
+		var names, stale []string
+		var l Level
+		for _, c := range unknown {
+			names = append(names, c.name)
+			if c.level.Stale {
+				stale = append(stale, c.level.Reason)
+				if c.level.EventTime.After(l.EventTime) {
+					l = c.level
+				}
+			}
+		}
+		if len(stale) > 0 {
+			l.Reason = strings.Join(stale, ", ")
+			return l
+		}
+		return Level{
+			Label:  river.Unknown.String(),
+			Reason: "No readings currently available from " + strings.Join(names, " or "),
+			Trend:  TrendUnknown,
+		}
+	case strategy == river.Weighted:
+		return weightedLevel(known)
+	}
+
+	// every other strategy chooses the level of a single gauge
+	chosen := known[0]
+	var note string
+	switch strategy {
+	case river.Freshest:
+		for _, c := range known[1:] {
+			if c.level.EventTime.After(chosen.level.EventTime) {
+				chosen = c
+			}
+		}
+		note = "freshest of " + gaugeCount(len(known))
+	case river.Max:
+		for _, c := range known[1:] {
+			if river.StringToLevel(c.level.Label) > river.StringToLevel(chosen.level.Label) {
+				chosen = c
+			}
+		}
+		note = "highest of " + gaugeCount(len(known))
+	case river.Min:
+		for _, c := range known[1:] {
+			if river.StringToLevel(c.level.Label) < river.StringToLevel(chosen.level.Label) {
+				chosen = c
+			}
+		}
+		note = "lowest of " + gaugeCount(len(known))
+	default:
+		// a higher priority gauge without a level is a fallback
+		if len(unknown) > 0 && unknown[0].order < chosen.order {
+			note = "as " + unknown[0].name + " unavailable"
+		}
+	}
+
+	l := chosen.level
+	if note != "" {
+		l.Reason += " (" + note + ")"
+	}
+	return l
+}
+
+// weightedLevel is the weighted mean of the known levels, with the trend
+// and times of the most heavily weighted gauge
+func weightedLevel(known []contribution) Level {
+	var sum, total float32
+	main := known[0]
+	var reasons []string
+	for _, c := range known {
+		sum += float32(river.StringToLevel(c.level.Label)) * c.weight
+		total += c.weight
+		if c.weight > main.weight {
+			main = c
+		}
+		reasons = append(reasons, c.level.Label+" from "+c.level.Reason)
+	}
+
+	l := main.level
+	l.Label = river.Level(math.Round(float64(sum / total))).String()
+	l.Reason = strings.Join(reasons, ", ")
+	return l
+}
+
+func sortByOrder(cs []contribution) {
+	sort.SliceStable(cs, func(i, j int) bool {
+		return cs[i].order < cs[j].order
+	})
+}
+
+func gaugeCount(n int) string {
+	return strconv.Itoa(n) + " gauges"
+}
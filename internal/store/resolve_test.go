@@ -0,0 +1,64 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/rainchasers/internal/river"
+)
+
+func TestResolveLevel(t *testing.T) {
+	now := time.Date(2020, 10, 6, 12, 0, 0, 0, time.UTC)
+	cals := []river.Calibration{
+		{URL: "rloi://1", Minimum: map[string]float32{"low": 1, "medium": 2, "high": 3}},
+		{URL: "rloi://2", Minimum: map[string]float32{"low": 1, "medium": 2, "high": 3}, Weight: 3},
+		{URL: "rloi://3", Minimum: map[string]float32{"low": 1, "medium": 2, "high": 3}, Priority: -1},
+	}
+	measure := func(i int, value float32, age time.Duration) Measure {
+		m := Measure{
+			Station:     gauge.Station{AliasURL: cals[i].URL, Name: "Gauge " + cals[i].URL[7:]},
+			Calibration: cals[i],
+		}
+		if age >= 0 {
+			m.Readings = []gauge.Reading{{EventTime: now.Add(-age), Value: value}}
+		}
+		return m
+	}
+	// gauge 1 is low, 2 is high and fresh, 3 is the priority gauge
+	low := measure(0, 1.5, time.Hour)
+	high := measure(1, 3.5, 0)
+	noReadings := measure(2, 0, -1)
+	medium := measure(2, 2.5, 2*time.Hour)
+
+	testCases := []struct {
+		strategy river.Strategy
+		measures []Measure
+		label    string
+		reason   string
+	}{
+		{river.Priority, []Measure{high, low}, "low", "1.50 at Gauge 1"},
+		{river.Priority, []Measure{low, high, medium}, "medium", "2.50 at Gauge 3"},
+		{"", []Measure{high, low, noReadings}, "low", "1.50 at Gauge 1 (as Gauge 3 unavailable)"},
+		{river.Freshest, []Measure{low, high, medium}, "high", "3.50 at Gauge 2 (freshest of 3 gauges)"},
+		{river.Max, []Measure{low, medium}, "medium", "2.50 at Gauge 3 (highest of 2 gauges)"},
+		{river.Min, []Measure{high, medium, noReadings}, "medium", "2.50 at Gauge 3 (lowest of 2 gauges)"},
+		{river.Weighted, []Measure{low, high}, "high", "low from 1.50 at Gauge 1, high from 3.50 at Gauge 2"},
+		{river.Weighted, []Measure{low, medium, noReadings}, "medium", "medium from 2.50 at Gauge 3, low from 1.50 at Gauge 1"},
+		{river.Max, []Measure{noReadings, measure(1, 0, -1)}, "unknown", "No readings currently available from Gauge 3 or Gauge 2"},
+	}
+
+	for i, tc := range testCases {
+		l := resolveLevel(tc.strategy, cals, tc.measures, now, DefaultTrendWindow)
+		if l.Label != tc.label {
+			t.Error(i, tc.strategy, "expected", tc.label, "got", l.Label, l.Reason)
+		}
+		if tc.reason != "" && l.Reason != tc.reason {
+			t.Error(i, tc.strategy, "unexpected reason", l.Reason)
+		}
+		if !strings.Contains(l.Reason, "Gauge") {
+			t.Error(i, tc.strategy, "reason does not explain the gauges", l.Reason)
+		}
+	}
+}
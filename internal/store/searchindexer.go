@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+	"github.com/robtuley/report"
+)
+
+// SearchIndexer keeps the river and station search indexes up to date
+type SearchIndexer interface {
+	StoreRecord(ctx context.Context, record *Record) report.Span
+	StoreStation(ctx context.Context, station gauge.Station) report.Span
+}
+
+// recordFields are the searchable river fields common to all indexers
+func recordFields(record *Record) map[string]interface{} {
+	s := record.Section
+	l := record.Level
+	return map[string]interface{}{
+		"slug":            s.Slug,
+		"section":         s.SectionName,
+		"river":           s.RiverName,
+		"grade":           s.Grade.Human,
+		"grade_numeric":   s.Grade.Average,
+		"desc":            s.Description,
+		"km":              s.KM,
+		"level_label":     l.Label,
+		"level_reason":    l.Reason,
+		"level_timestamp": l.EventTime,
+		"level_trend":     l.Trend,
+		"level_rate":      l.RatePerHour,
+		"level_stale":     l.Stale,
+	}
+}
+
+// stationFields are the searchable station fields common to all indexers
+func stationFields(station gauge.Station) map[string]interface{} {
+	return map[string]interface{}{
+		"alias_url": station.AliasURL,
+		"human_url": station.HumanURL,
+		"name":      station.Name,
+		"name_cy":   station.NameCY,
+		"river":     station.RiverName,
+		"type":      station.Type,
+	}
+}
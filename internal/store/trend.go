@@ -0,0 +1,89 @@
+package store
+
+import (
+	"math"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+// Trend describes the direction a river level is moving
+const (
+	TrendUnknown = "unknown"
+	TrendRising  = "rising"
+	TrendFalling = "falling"
+	TrendSteady  = "steady"
+)
+
+// DefaultTrendWindow is the period of readings a trend is calculated over
+const DefaultTrendWindow = 3 * time.Hour
+
+// minTrendChange is the smallest change over the window that is not steady
+const minTrendChange = 0.02
+
+// trend fits a least squares line through the readings within window of
+// the latest (readings are most recent first by convention)
+//
+// A regression rather than the difference between the first and last reading
+// copes with irregularly spaced readings, and the trend is only rising or
+// falling if the slope is significant against the noise in the readings.
+func trend(readings []gauge.Reading, window time.Duration) (string, float32) {
+	if len(readings) == 0 {
+		return TrendUnknown, 0
+	}
+	latest := readings[0].EventTime
+
+	// x is hours before the latest reading
+	var xs, ys []float64
+	for _, r := range readings {
+		age := latest.Sub(r.EventTime)
+		if age > window || age < 0 {
+			continue
+		}
+		xs = append(xs, -age.Hours())
+		ys = append(ys, float64(r.Value))
+	}
+
+	// need enough readings covering at least a quarter of the window
+	n := float64(len(xs))
+	if len(xs) < 3 || -xs[len(xs)-1] < window.Hours()/4 {
+		return TrendUnknown, 0
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return TrendUnknown, 0
+	}
+	slope := sxy / sxx
+
+	// standard error of the slope from the residuals
+	var ssr float64
+	for i := range xs {
+		residual := ys[i] - (meanY + slope*(xs[i]-meanX))
+		ssr += residual * residual
+	}
+	stdErr := math.Sqrt(ssr / (n - 2) / sxx)
+
+	rate := float32(slope)
+	switch {
+	case math.Abs(slope*window.Hours()) < minTrendChange:
+		return TrendSteady, rate
+	case math.Abs(slope) < 2*stdErr:
+		return TrendSteady, rate
+	case slope > 0:
+		return TrendRising, rate
+	}
+	return TrendFalling, rate
+}
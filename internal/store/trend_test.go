@@ -0,0 +1,49 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/robtuley/rainchasers/internal/gauge"
+)
+
+func readingsAt(start time.Time, offsets []time.Duration, values []float32) []gauge.Reading {
+	var rs []gauge.Reading
+	for i := len(offsets) - 1; i >= 0; i-- {
+		rs = append(rs, gauge.Reading{EventTime: start.Add(offsets[i]), Value: values[i]})
+	}
+	return rs
+}
+
+func TestTrend(t *testing.T) {
+	start := time.Date(2020, 10, 6, 12, 0, 0, 0, time.UTC)
+	m := time.Minute
+	regular := []time.Duration{0, 15 * m, 30 * m, 45 * m, 60 * m, 75 * m, 90 * m, 105 * m, 120 * m}
+	irregular := []time.Duration{0, 5 * m, 70 * m, 80 * m, 150 * m}
+
+	testCases := []struct {
+		name     string
+		readings []gauge.Reading
+		trend    string
+		rate     float32
+	}{
+		{"rising", readingsAt(start, regular, []float32{1.0, 1.05, 1.1, 1.15, 1.2, 1.25, 1.3, 1.35, 1.4}), TrendRising, 0.2},
+		{"falling irregular", readingsAt(start, irregular, []float32{2.0, 1.99, 1.8, 1.77, 1.5}), TrendFalling, -0.2},
+		{"steady", readingsAt(start, regular, []float32{1, 1, 1.01, 1, 1, 1, 1.01, 1, 1}), TrendSteady, 0},
+		{"noisy", readingsAt(start, regular, []float32{1.0, 1.3, 0.9, 1.25, 1.0, 0.8, 1.3, 0.95, 1.1}), TrendSteady, 0},
+		{"too few", readingsAt(start, regular[:2], []float32{1.0, 1.5}), TrendUnknown, 0},
+		{"too short", readingsAt(start, regular[:3], []float32{1.0, 1.5, 2.0}), TrendUnknown, 0},
+		{"none", nil, TrendUnknown, 0},
+	}
+
+	for _, tc := range testCases {
+		trend, rate := trend(tc.readings, DefaultTrendWindow)
+		if trend != tc.trend {
+			t.Error(tc.name, "expected", tc.trend, "got", trend)
+		}
+		if tc.rate != 0 && math.Abs(float64(rate-tc.rate)) > 0.02 {
+			t.Error(tc.name, "expected rate near", tc.rate, "got", rate)
+		}
+	}
+}